@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+	"github.com/urfave/cli/v2"
+)
+
+func catalogPath(ctx *cli.Context) string {
+	return filepath.Join(ctx.String("data-dir"), "catalog.json")
+}
+
+// recordVod records that the vod of metadata was alive, or that it vanished, in the catalog and the expiry tracker.
+func recordVod(s *session, metadata *vods.PlaylistMetadata, file string, alive bool) {
+	status := vods.CatalogAlive
+	if !alive {
+		status = vods.CatalogVanished
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	trackVod(s, metadata, file, alive)
+	catalog, err := vods.LoadCatalog(catalogPath(s.ctx))
+	if err == nil {
+		catalog.Add(metadata, file, status, time.Now())
+		err = catalog.Save(catalogPath(s.ctx))
+	}
+	if err != nil {
+		s.println(fmt.Sprint("Failed to update the catalog: ", err))
+	}
+}
+
+// parseDate parses a date in the format 2006-01-02 or a time in the format RFC 3339.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New(fmt.Sprint("date ", value, " is not in the format 2006-01-02 or 2006-01-02T15:04:05Z"))
+	}
+	return t, nil
+}
+
+func catalogQuery(ctx *cli.Context) (vods.CatalogQuery, error) {
+	query := vods.CatalogQuery{
+		Streamer:    ctx.String("streamer"),
+		MinDuration: ctx.Duration("min-duration"),
+		MaxDuration: ctx.Duration("max-duration"),
+		Status:      ctx.String("status"),
+	}
+	var err error
+	if ctx.String("since") != "" {
+		if query.Since, err = parseDate(ctx.String("since")); err != nil {
+			return query, err
+		}
+	}
+	if ctx.String("until") != "" {
+		if query.Until, err = parseDate(ctx.String("until")); err != nil {
+			return query, err
+		}
+	}
+	return query, nil
+}
+
+var catalogCsvHeader = []string{"streamer", "videoId", "startTime", "durationSeconds", "domain", "url", "pathScheme", "status", "file", "resolvedAt", "updatedAt"}
+
+func writeCatalogCsv(entries []vods.CatalogEntry) error {
+	writer := csv.NewWriter(os.Stdout)
+	writer.Write(catalogCsvHeader)
+	for _, entry := range entries {
+		resolvedAt := ""
+		if entry.ResolvedAt != nil {
+			resolvedAt = entry.ResolvedAt.Format(time.RFC3339)
+		}
+		writer.Write([]string{
+			entry.Streamer,
+			entry.VideoId,
+			entry.StartTime.Format(time.RFC3339),
+			strconv.FormatFloat(entry.DurationSeconds, 'f', 3, 64),
+			entry.Domain,
+			entry.Url,
+			entry.PathScheme,
+			entry.Status,
+			entry.File,
+			resolvedAt,
+			entry.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeCatalogEntries(ctx *cli.Context, entries []vods.CatalogEntry) error {
+	format := ctx.String("format")
+	if format == "" {
+		format = "text"
+		if ctx.String("output") == "json" {
+			format = "jsonl"
+		}
+	}
+	switch format {
+	case "text":
+		for _, entry := range entries {
+			duration := time.Duration(entry.DurationSeconds * float64(time.Second)).Truncate(time.Second)
+			fmt.Println(fmt.Sprint(entry.StartTime.Format(time.RFC3339), " ", entry.Streamer, " ", entry.VideoId, " ", duration, " ", entry.Status, " ", entry.File))
+		}
+	case "jsonl":
+		encoder := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			encoder.Encode(entry)
+		}
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	case "csv":
+		return writeCatalogCsv(entries)
+	default:
+		return errors.New(fmt.Sprint("format ", format, " is not text, jsonl, json or csv"))
+	}
+	return nil
+}
+
+var catalogFormatFlag = &cli.StringFlag{
+	Name:  "format",
+	Usage: "text, jsonl (a line of JSON per vod), json (an array) or csv (default: text, or jsonl with --output json)",
+}
+
+func catalogAction(ctx *cli.Context) error {
+	query, err := catalogQuery(ctx)
+	if err != nil {
+		return err
+	}
+	catalog, err := vods.LoadCatalog(catalogPath(ctx))
+	if err != nil {
+		return err
+	}
+	return writeCatalogEntries(ctx, catalog.Search(query))
+}
+
+var listCommand = &cli.Command{
+	Name:   "list",
+	Usage:  "List every vod in the catalog, the most recent first",
+	Flags:  []cli.Flag{catalogFormatFlag},
+	Action: catalogAction,
+}
+
+var searchCommand = &cli.Command{
+	Name:  "search",
+	Usage: "List the vods in the catalog that match every filter, the most recent first",
+	Flags: []cli.Flag{
+		catalogFormatFlag,
+		&cli.StringFlag{
+			Name:  "streamer",
+			Usage: "twitch streamer name",
+		},
+		&cli.StringFlag{
+			Name:  "since",
+			Usage: "only vods that started at or after this date, e.g. 2024-01-01",
+		},
+		&cli.StringFlag{
+			Name:  "until",
+			Usage: "only vods that started before this date",
+		},
+		&cli.DurationFlag{
+			Name:  "min-duration",
+			Usage: "only vods at least this long, e.g. 2h",
+		},
+		&cli.DurationFlag{
+			Name:  "max-duration",
+			Usage: "only vods at most this long",
+		},
+		&cli.StringFlag{
+			Name:  "status",
+			Usage: "only vods that are alive or vanished",
+		},
+	},
+	Action: catalogAction,
+}
+
+var catalogImportCommand = &cli.Command{
+	Name:      "catalog-import",
+	Usage:     "Add the playlists that have a .json sidecar to the catalog, e.g. ones written before the catalog existed",
+	ArgsUsage: "[file.m3u8|directory]... (default: the output directory)",
+	Action: func(ctx *cli.Context) error {
+		paths := ctx.Args().Slice()
+		if len(paths) == 0 {
+			paths = []string{ctx.String("output-dir")}
+		}
+		playlists, err := findPlaylists(paths)
+		if err != nil {
+			return err
+		}
+		catalog, err := vods.LoadCatalog(catalogPath(ctx))
+		if err != nil {
+			return err
+		}
+		numImported := 0
+		for _, path := range playlists {
+			metadata, err := vods.LoadPlaylistMetadata(vods.SidecarPath(path))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			status := vods.CatalogAlive
+			if metadata.VanishedAt != nil {
+				status = vods.CatalogVanished
+			}
+			catalog.Add(metadata, path, status, time.Now())
+			numImported++
+		}
+		fmt.Println(fmt.Sprint("Imported ", numImported, " vods"))
+		return catalog.Save(catalogPath(ctx))
+	},
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/auoie/goVods/vods"
+	"github.com/urfave/cli/v2"
+)
+
+// clientConfig is the config file for outbound requests. Flags override it.
+type clientConfig struct {
+	Proxy     string            `json:"proxy"` // e.g. socks5://127.0.0.1:1080
+	UserAgent string            `json:"userAgent"`
+	Headers   map[string]string `json:"headers"`   // e.g. {"Accept-Language": "en"}
+	CABundles []string          `json:"caBundles"` // PEM files trusted in addition to the system certificates
+	Resolve   map[string]string `json:"resolve"`   // e.g. {"vod-secure.twitch.tv": "151.101.2.167"}
+	IPVersion string            `json:"ipVersion"` // "4" or "6" to only use one IP version
+}
+
+func configPath(ctx *cli.Context) string {
+	if path := ctx.String("config"); path != "" {
+		return path
+	}
+	return filepath.Join(ctx.String("data-dir"), "config.json")
+}
+
+func loadClientConfig(ctx *cli.Context) (*clientConfig, error) {
+	config := &clientConfig{Headers: map[string]string{}, Resolve: map[string]string{}}
+	data, err := os.ReadFile(configPath(ctx))
+	if errors.Is(err, os.ErrNotExist) && ctx.String("config") == "" {
+		return config, config.applyFlags(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, errors.New(fmt.Sprint("invalid config file ", configPath(ctx), ": ", err))
+	}
+	if config.Headers == nil {
+		config.Headers = map[string]string{}
+	}
+	if config.Resolve == nil {
+		config.Resolve = map[string]string{}
+	}
+	return config, config.applyFlags(ctx)
+}
+
+func (config *clientConfig) applyFlags(ctx *cli.Context) error {
+	if ctx.IsSet("proxy") {
+		config.Proxy = ctx.String("proxy")
+	}
+	if ctx.IsSet("user-agent") {
+		config.UserAgent = ctx.String("user-agent")
+	}
+	if ctx.IsSet("ip-version") {
+		config.IPVersion = ctx.String("ip-version")
+	}
+	config.CABundles = append(config.CABundles, ctx.StringSlice("ca-bundle")...)
+	for _, header := range ctx.StringSlice("header") {
+		key, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return errors.New(fmt.Sprint("header ", header, " is not in the format 'Key: Value'"))
+		}
+		config.Headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	for _, resolve := range ctx.StringSlice("resolve") {
+		host, ip, ok := strings.Cut(resolve, ":")
+		if !ok {
+			return errors.New(fmt.Sprint("resolve ", resolve, " is not in the format 'host:ip'"))
+		}
+		config.Resolve[host] = ip
+	}
+	return nil
+}
+
+func (config *clientConfig) toClientOptions(options vods.ClientOptions) (vods.ClientOptions, error) {
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil {
+			return options, err
+		}
+		options.ProxyURL = proxyURL
+	}
+	options.UserAgent = config.UserAgent
+	options.Headers = http.Header{}
+	for key, value := range config.Headers {
+		options.Headers.Set(key, value)
+	}
+	if len(config.CABundles) > 0 {
+		pool, err := vods.LoadCertPool(config.CABundles)
+		if err != nil {
+			return options, err
+		}
+		options.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	options.Resolve = config.Resolve
+	switch config.IPVersion {
+	case "":
+	case "4":
+		options.Network = "tcp4"
+	case "6":
+		options.Network = "tcp6"
+	default:
+		return options, errors.New(fmt.Sprint("ip version ", config.IPVersion, " is not 4 or 6"))
+	}
+	return options, nil
+}
+
+var clientFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "config",
+		Usage: "client config file (default: config.json in the data directory)",
+	},
+	&cli.StringFlag{
+		Name:  "proxy",
+		Usage: "proxy for all requests, e.g. http://host:port or socks5://host:port",
+	},
+	&cli.StringFlag{
+		Name:  "user-agent",
+		Usage: "User-Agent header of all requests",
+	},
+	&cli.StringSliceFlag{
+		Name:  "header",
+		Usage: "extra header of all requests in the format 'Key: Value'",
+	},
+	&cli.StringSliceFlag{
+		Name:  "ca-bundle",
+		Usage: "PEM file of certificates to trust in addition to the system certificates",
+	},
+	&cli.StringSliceFlag{
+		Name:  "resolve",
+		Usage: "connect to a host at a fixed ip address in the format 'host:ip'",
+	},
+	&cli.StringFlag{
+		Name:  "ip-version",
+		Usage: "only connect over IPv4 (4) or IPv6 (6)",
+	},
+}
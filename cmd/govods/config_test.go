@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func writeTestConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+	return path
+}
+
+func TestLoadClientConfig(t *testing.T) {
+	path := writeTestConfig(t, `{
+		"proxy": "socks5://127.0.0.1:1080",
+		"userAgent": "govods",
+		"headers": {"Accept-Language": "en"},
+		"resolve": {"vod-secure.twitch.tv": "151.101.2.167"},
+		"ipVersion": "4"
+	}`)
+	ctx := newTestContext(t, nil, "--config", path, "--user-agent", "govods-test", "--header", "X-Govods: 1", "--resolve", "vod-metro.twitch.tv:151.101.2.168")
+	config, err := loadClientConfig(ctx)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	// flags override the config file
+	assertEqual(t, config.Proxy, "socks5://127.0.0.1:1080")
+	assertEqual(t, config.UserAgent, "govods-test")
+	assertEqual(t, config.Headers["Accept-Language"], "en")
+	assertEqual(t, config.Headers["X-Govods"], "1")
+	assertEqual(t, config.Resolve["vod-secure.twitch.tv"], "151.101.2.167")
+	assertEqual(t, config.Resolve["vod-metro.twitch.tv"], "151.101.2.168")
+
+	options, err := config.toClientOptions(vods.DefaultClientOptions)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, options.ProxyURL.String(), "socks5://127.0.0.1:1080")
+	assertEqual(t, options.UserAgent, "govods-test")
+	assertEqual(t, options.Headers.Get("X-Govods"), "1")
+	assertEqual(t, options.Network, "tcp4")
+	assertEqual(t, options.Resolve["vod-metro.twitch.tv"], "151.101.2.168")
+}
+
+func TestLoadClientConfigDefault(t *testing.T) {
+	// without --config, a missing config.json in the data directory is an empty config
+	config, err := loadClientConfig(newTestContext(t, nil))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, config.Proxy, "")
+	assertEqual(t, len(config.Headers), 0)
+	if _, err := loadClientConfig(newTestContext(t, nil, "--config", filepath.Join(t.TempDir(), "missing.json"))); err == nil {
+		t.Fatalf("loaded a missing --config")
+	}
+}
+
+func TestLoadClientConfigRejects(t *testing.T) {
+	for _, test := range []struct {
+		config string
+		args   []string
+	}{
+		{config: `{"proxy": "socks5://127.0.0.1:1080", "proxies": []}`},
+		{config: `{"headers": ["Accept-Language: en"]}`},
+		{config: `{}`, args: []string{"--header", "X-Govods"}},
+		{config: `{}`, args: []string{"--resolve", "vod-secure.twitch.tv"}},
+	} {
+		ctx := newTestContext(t, nil, append([]string{"--config", writeTestConfig(t, test.config)}, test.args...)...)
+		if _, err := loadClientConfig(ctx); err == nil {
+			t.Fatalf("loaded config %v with %v", test.config, test.args)
+		}
+	}
+	ctx := newTestContext(t, nil, "--config", writeTestConfig(t, `{"unknown": 1}`))
+	_, err := loadClientConfig(ctx)
+	if err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Fatalf("got %v want an error about the unknown field", err)
+	}
+}
+
+func TestToClientOptionsRejects(t *testing.T) {
+	for _, config := range []*clientConfig{
+		{IPVersion: "5"},
+		{Proxy: "socks5://[::1"},
+		{CABundles: []string{filepath.Join(t.TempDir(), "missing.pem")}},
+	} {
+		if _, err := config.toClientOptions(vods.DefaultClientOptions); err == nil {
+			t.Fatalf("made client options of %+v", config)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+	"github.com/urfave/cli/v2"
+)
+
+func expiryPath(ctx *cli.Context) string {
+	return filepath.Join(ctx.String("data-dir"), "expiry.json")
+}
+
+func retentionPath(ctx *cli.Context) string {
+	return filepath.Join(ctx.String("data-dir"), "retention.json")
+}
+
+// trackVod records in the expiry tracker that the vod of metadata was alive, or that it vanished.
+// The caller holds stateMu, so the tracker and the catalog are updated together.
+func trackVod(s *session, metadata *vods.PlaylistMetadata, file string, alive bool) {
+	tracker, err := vods.LoadExpiryTracker(expiryPath(s.ctx))
+	if err == nil {
+		if alive {
+			tracker.SeenAlive(metadata, file, time.Now())
+		} else {
+			tracker.SeenVanished(metadata, time.Now())
+		}
+		err = tracker.Save(expiryPath(s.ctx))
+	}
+	if err != nil {
+		s.println(fmt.Sprint("Failed to track expiry: ", err))
+	}
+}
+
+var expiringCommand = &cli.Command{
+	Name:  "expiring",
+	Usage: "List the vods that were found and are estimated to be deleted soon, using the retention rules in retention.json in the data directory",
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "within",
+			Usage: "list the vods estimated to be deleted within this long",
+			Value: 72 * time.Hour,
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		jsonOutput, err := parseOutput(ctx.String("output"))
+		if err != nil {
+			return err
+		}
+		tracker, err := vods.LoadExpiryTracker(expiryPath(ctx))
+		if err != nil {
+			return err
+		}
+		rules, err := vods.LoadRetentionRules(retentionPath(ctx))
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, vod := range tracker.Expiring(rules, now, ctx.Duration("within")) {
+			if jsonOutput {
+				json.NewEncoder(os.Stdout).Encode(vod)
+				continue
+			}
+			remaining := vod.EstimatedDeletion.Sub(now).Truncate(time.Minute)
+			when := fmt.Sprint("in ", remaining)
+			if remaining < 0 {
+				when = fmt.Sprint(-remaining, " ago")
+			}
+			fmt.Println(fmt.Sprint(vod.Streamer, " ", vod.VideoId, " started ", vod.StartTime.Format(time.RFC3339), ", estimated to be deleted ", when, " ", vod.File))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+	"github.com/urfave/cli/v2"
+)
+
+func hooksPath(ctx *cli.Context) string {
+	if path := ctx.String("hooks"); path != "" {
+		return path
+	}
+	return filepath.Join(ctx.String("data-dir"), "hooks.json")
+}
+
+// newHookEvent returns an event about the vod of metadata, which is nil if a refresh failed before reading it.
+func newHookEvent(event string, metadata *vods.PlaylistMetadata, file string, result interface{}) *vods.HookEvent {
+	hookEvent := &vods.HookEvent{Event: event, File: file, Result: result}
+	if metadata != nil {
+		hookEvent.Streamer = metadata.Streamer
+		hookEvent.VideoId = metadata.VideoId
+		hookEvent.Url = metadata.Url
+	}
+	return hookEvent
+}
+
+// A hookWorker fires the hooks of events in the background, one event at a time in the order they were queued,
+// so a slow hook holds up other events instead of the lookups. Queueing waits while the queue is full.
+type hookWorker struct {
+	mu     sync.RWMutex
+	closed bool
+	events chan *vods.HookEvent
+	done   chan struct{}
+}
+
+const hookQueueSize = 100
+
+// startHookWorker fires the events with the hooks of s, reporting failures with the messages of s.
+func startHookWorker(s *session) *hookWorker {
+	worker := &hookWorker{events: make(chan *vods.HookEvent, hookQueueSize), done: make(chan struct{})}
+	go func() {
+		defer close(worker.done)
+		for event := range worker.events {
+			for _, err := range s.hooks.Fire(s.ctx.Context, event) {
+				s.println(err)
+			}
+		}
+	}()
+	return worker
+}
+
+// queue returns false if the worker is closed.
+func (worker *hookWorker) queue(event *vods.HookEvent) bool {
+	worker.mu.RLock()
+	defer worker.mu.RUnlock()
+	if worker.closed {
+		return false
+	}
+	worker.events <- event
+	return true
+}
+
+// close waits for the queued events.
+func (worker *hookWorker) close() {
+	worker.mu.Lock()
+	if !worker.closed {
+		worker.closed = true
+		close(worker.events)
+	}
+	worker.mu.Unlock()
+	<-worker.done
+}
+
+// fireHooks queues the hooks of the event. Hooks that fail are reported and don't fail the command.
+func (s *session) fireHooks(event *vods.HookEvent) {
+	if len(s.hooks.Hooks) == 0 {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	// the record could change before the worker gets to the event
+	result, err := json.Marshal(event.Result)
+	if err != nil {
+		s.println(err)
+		return
+	}
+	event.Result = json.RawMessage(result)
+	if !s.hookWorker.queue(event) {
+		s.println(fmt.Sprint("Skipped the hooks of ", event.Event, " after the command finished"))
+	}
+}
+
+// fireLookupHooks fires found and segments-missing for a lookup that wrote a playlist, not-found for one
+// that found no playlist, or failed for one that couldn't tell, e.g. because it was rate limited.
+func (s *session) fireLookupHooks(record *lookupRecord, err error) {
+	if err == nil {
+		s.fireHooks(newHookEvent(vods.HookFound, record.PlaylistMetadata, record.File, record))
+		if record.MissingSegments != nil && *record.MissingSegments > 0 {
+			s.fireHooks(newHookEvent(vods.HookSegmentsMissing, record.PlaylistMetadata, record.File, record))
+		}
+		return
+	}
+	switch errorType(err) {
+	case "canceled":
+	case "not-found", "window-exhausted", "no-candidates":
+		s.fireHooks(newHookEvent(vods.HookNotFound, record.PlaylistMetadata, record.File, record))
+	default:
+		s.fireHooks(newHookEvent(vods.HookFailed, record.PlaylistMetadata, record.File, record))
+	}
+}
+
+// fireRefreshHooks fires not-found for a playlist that vanished, failed for one that couldn't be checked,
+// or segments-missing for one that was updated and is missing segments.
+func (s *session) fireRefreshHooks(record *refreshRecord) {
+	switch {
+	case record.Status == refreshVanished:
+		s.fireHooks(newHookEvent(vods.HookNotFound, record.PlaylistMetadata, record.File, record))
+	case record.Status == refreshFailed && record.ErrorType != "canceled":
+		s.fireHooks(newHookEvent(vods.HookFailed, record.PlaylistMetadata, record.File, record))
+	case record.Status == refreshUpdated && record.MissingSegments != nil && *record.MissingSegments > 0:
+		s.fireHooks(newHookEvent(vods.HookSegmentsMissing, record.PlaylistMetadata, record.File, record))
+	}
+}
+
+// hookTestServer answers webhooks of the hooks-test command and prints what it received.
+type hookTestServer struct {
+	mu        sync.Mutex
+	failFirst int // number of requests of each hook that are answered with 503
+	requests  map[string]int
+}
+
+func (server *hookTestServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.requests[req.URL.Path]++
+	attempt := server.requests[req.URL.Path]
+	headers := []string{}
+	for key := range req.Header {
+		headers = append(headers, fmt.Sprint(key, ": ", req.Header.Get(key)))
+	}
+	sort.Strings(headers)
+	fmt.Println(fmt.Sprint(req.Method, " ", req.URL.Path, " (attempt ", attempt, ")"))
+	for _, header := range headers {
+		fmt.Println("  " + header)
+	}
+	fmt.Println("  " + strings.TrimSpace(string(body)))
+	if attempt <= server.failFirst {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+var hooksTestCommand = &cli.Command{
+	Name:  "hooks-test",
+	Usage: "Fire a sample of each event at the hooks, sending webhooks to a local server that prints them unless --live is set",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "event",
+			Usage: "only fire this event: " + strings.Join(vods.HookEvents, ", "),
+		},
+		&cli.BoolFlag{
+			Name:  "live",
+			Usage: "send webhooks to their urls instead of the local server",
+		},
+		&cli.IntFlag{
+			Name:  "fail-first",
+			Usage: "answer the first N requests of each webhook with 503 to exercise the retries",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		hooks, err := vods.LoadHooks(hooksPath(ctx))
+		if err != nil {
+			return err
+		}
+		if len(hooks.Hooks) == 0 {
+			return errors.New(fmt.Sprint("there are no hooks in ", hooksPath(ctx)))
+		}
+		if !ctx.Bool("live") {
+			server := httptest.NewServer(&hookTestServer{failFirst: ctx.Int("fail-first"), requests: map[string]int{}})
+			defer server.Close()
+			for i, hook := range hooks.Hooks {
+				if hook.Url != "" {
+					hook.Url = fmt.Sprint(server.URL, "/hook", i)
+				}
+			}
+		}
+		events := ctx.StringSlice("event")
+		if len(events) == 0 {
+			events = vods.HookEvents
+		}
+		record := newLookupRecord(&vods.VideoData{StreamerName: "streamer", VideoId: "0", Time: time.Now().Truncate(time.Second)}, "hooks-test")
+		record.File = filepath.Join(ctx.String("output-dir"), "streamer", "sample.m3u8")
+		numFailed := 0
+		for _, event := range events {
+			errs := hooks.Fire(ctx.Context, newHookEvent(event, record.PlaylistMetadata, record.File, record))
+			for _, err := range errs {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			numFailed += len(errs)
+		}
+		if numFailed > 0 {
+			return errors.New(fmt.Sprint(numFailed, " hooks failed"))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func TestFireLookupHooks(t *testing.T) {
+	var mu sync.Mutex
+	events := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received := vods.HookEvent{}
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &received)
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, received.Event)
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "hooks.json")
+	if err := os.WriteFile(path, []byte(`{"hooks": [{"url": "`+server.URL+`"}]}`), 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+	s := newTestSession(t, newTestContext(t, nil, "--hooks", path))
+	for _, err := range []error{
+		nil,
+		fmt.Errorf("lookup: %w", vods.ErrNotFound),
+		vods.ErrNoCandidates,
+		fmt.Errorf("lookup: %w", &vods.StatusError{StatusCode: http.StatusTooManyRequests}),
+		context.Canceled,
+		errors.New("disk full"),
+	} {
+		record := newLookupRecord(testVideoData, "test")
+		record.setError(err)
+		s.fireLookupHooks(record, err)
+	}
+	s.hookWorker.close()
+	mu.Lock()
+	defer mu.Unlock()
+	assertEqual(t, strings.Join(events, " "), "found not-found not-found failed failed")
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+	"github.com/grafov/m3u8"
+	"github.com/urfave/cli/v2"
+)
+
+// inspectRecord is the result of inspecting a playlist, written as a line of JSON with --output json.
+type inspectRecord struct {
+	File string `json:"file"`
+	*vods.PlaylistSummary
+	ValidSegments *int                `json:"validSegments,omitempty"` // only set with --check
+	MissingRanges []vods.SegmentRange `json:"missingRanges,omitempty"`
+	Warnings      []string            `json:"warnings,omitempty"`
+}
+
+func isUrl(file string) bool {
+	return strings.HasPrefix(file, "https://") || strings.HasPrefix(file, "http://")
+}
+
+// loadPlaylist reads a playlist from a file or url. The segments of a url are muted, like the playlists
+// that are written, because the CDN doesn't serve -unmuted segments, and the segment urls of an
+// index-dvr url are made explicit.
+func loadPlaylist(file string, s *session) (mediapl *m3u8.MediaPlaylist, warning error, err error) {
+	var data []byte
+	if isUrl(file) {
+		data, err = vods.GetPlaylistBody(s.ctx.Context, s.client, file)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	mediapl, warning, err = vods.DecodeMediaPlaylistLenient(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if isUrl(file) {
+		vods.MuteMediaSegments(mediapl)
+		if dwp, err := vods.UrlToDomainWithPath(file); err == nil {
+			dwp.MakePathsExplicit(mediapl)
+		}
+	}
+	return mediapl, warning, nil
+}
+
+func inspect(file string, s *session) (*inspectRecord, error) {
+	mediapl, warning, err := loadPlaylist(file, s)
+	if err != nil {
+		return nil, err
+	}
+	record := &inspectRecord{File: file, PlaylistSummary: vods.SummarizeMediaPlaylist(mediapl)}
+	if warning != nil {
+		record.Warnings = append(record.Warnings, warning.Error())
+	}
+	validator, err := makeSegmentValidator(s.ctx, s.ctx.String("check"), s.reporter)
+	if err != nil || validator == nil {
+		return record, err
+	}
+	results, err := vods.ValidateSegments(s.ctx.Context, mediapl, validator, s.client, s.prober)
+	finishProgress(s.reporter)
+	if err != nil {
+		return nil, err
+	}
+	numValidSegments := 0
+	for _, result := range results {
+		if result.Valid {
+			numValidSegments++
+		}
+	}
+	record.ValidSegments = &numValidSegments
+	record.MissingRanges = vods.MissingRanges(results)
+	return record, nil
+}
+
+func formatRanges(ranges []vods.SegmentRange) string {
+	formatted := []string{}
+	for _, r := range ranges {
+		if r.End-r.Start == 1 {
+			formatted = append(formatted, fmt.Sprint(r.Start))
+		} else {
+			formatted = append(formatted, fmt.Sprint(r.Start, "-", r.End-1))
+		}
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func (record *inspectRecord) print() {
+	fmt.Println(record.File)
+	if record.UrlPath != "" {
+		fmt.Println(fmt.Sprint("  Video: ", record.Streamer, " ", record.VideoId, " started at ", record.StartTime.UTC().Format(time.RFC3339)))
+	}
+	fmt.Println(fmt.Sprint("  Duration: ", (time.Duration(record.DurationSeconds * float64(time.Second))).Truncate(time.Second)))
+	fmt.Println(fmt.Sprint("  Segments: ", record.Segments))
+	if len(record.MutedRanges) > 0 {
+		fmt.Println(fmt.Sprint("  Muted segments: ", formatRanges(record.MutedRanges)))
+	}
+	if len(record.NumberGaps) > 0 {
+		fmt.Println(fmt.Sprint("  Skipped segment numbers: ", formatRanges(record.NumberGaps)))
+	}
+	for _, domain := range record.Domains {
+		fmt.Println(fmt.Sprint("  Domain: ", domain.Domain, " (", domain.Segments, " segments)"))
+	}
+	if record.TwitchTotalSecs != nil {
+		fmt.Println(fmt.Sprint("  Twitch total seconds: ", *record.TwitchTotalSecs))
+	}
+	if record.ValidSegments != nil {
+		fmt.Println(fmt.Sprint("  Available segments: ", *record.ValidSegments, " out of ", record.Segments))
+		if len(record.MissingRanges) > 0 {
+			fmt.Println(fmt.Sprint("  Missing segments: ", formatRanges(record.MissingRanges)))
+		}
+	}
+	for _, warning := range record.Warnings {
+		fmt.Println(fmt.Sprint("  Warning: ", warning))
+	}
+}
+
+var inspectCommand = &cli.Command{
+	Name:      "inspect",
+	Usage:     "Describe .m3u8 files or urls: duration, segments, video data, muted segments, skipped segments and domains",
+	ArgsUsage: "<file.m3u8|url>...",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  "check",
+			Usage: "check which segments are available now with concurrency level, or 'auto' to adapt the concurrency",
+		},
+	}, samplingFlags...),
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() == 0 {
+			return errors.New("no playlists to inspect")
+		}
+		s, err := newSession(ctx)
+		if err != nil {
+			return err
+		}
+		defer s.close()
+		for _, file := range ctx.Args().Slice() {
+			record, err := inspect(file, s)
+			if err != nil {
+				return errors.New(fmt.Sprint(file, ": ", err))
+			}
+			if s.jsonOutput {
+				s.writeJson(record)
+			} else {
+				record.print()
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	jobLookup   = "lookup"   // find and write the playlists of streams
+	jobValidate = "validate" // refresh written playlists
+	jobDownload = "download" // lookup, then run the download command of the server
+)
+
+const (
+	jobQueued   = "queued"
+	jobRunning  = "running"
+	jobDone     = "done"
+	jobCanceled = "canceled"
+)
+
+const maxJobLogLines = 200
+
+// A jobRequest is the body of a request that submits a job.
+type jobRequest struct {
+	Type          string          `json:"type"`
+	Format        string          `json:"format"`   // of video and videos: govods (default), sullygnome or twitchtracker
+	Video         json.RawMessage `json:"video"`    // a single stream
+	Videos        json.RawMessage `json:"videos"`   // a batch of streams, such as the JSON read by the stdin command
+	Files         []string        `json:"files"`    // playlists in the output directory to validate
+	VideoIds      []string        `json:"videoIds"` // vods in the catalog to validate
+	FilterInvalid *string         `json:"filterInvalid"`
+	Sample        *int            `json:"sample"`
+	Verify        *bool           `json:"verify"`
+	Check         *string         `json:"check"` // how validate jobs check segments, like --check of refresh
+}
+
+// A jobResult is the result of a single stream or playlist of a job.
+type jobResult struct {
+	*lookupRecord
+	Status        string `json:"status,omitempty"` // of validate jobs: unchanged, updated, vanished or failed
+	Downloaded    bool   `json:"downloaded,omitempty"`
+	DownloadError string `json:"downloadError,omitempty"`
+}
+
+type jobProgress struct {
+	UrlsTried       int `json:"urlsTried"`
+	UrlsInvalid     int `json:"urlsInvalid"`
+	SegmentsChecked int `json:"segmentsChecked"`
+	SegmentsTotal   int `json:"segmentsTotal"`
+}
+
+// A jobView is the state of a job returned by the api.
+type jobView struct {
+	Id         string      `json:"id"`
+	Type       string      `json:"type"`
+	Status     string      `json:"status"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	StartedAt  *time.Time  `json:"startedAt,omitempty"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+	Total      int         `json:"total"`
+	Completed  int         `json:"completed"`
+	Failed     int         `json:"failed"`
+	Progress   jobProgress `json:"progress"` // of the stream or playlist being processed
+	Results    []jobResult `json:"results"`
+	Log        []string    `json:"log"`
+}
+
+type job struct {
+	mu      sync.Mutex
+	view    jobView
+	request *jobRequest
+	flags   *flag.FlagSet // the flags of the job, set when it is submitted
+	videos  []vods.VideoData
+	files   []string
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func (j *job) snapshot() jobView {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	view := j.view
+	view.Results = append([]jobResult{}, j.view.Results...)
+	view.Log = append([]string{}, j.view.Log...)
+	return view
+}
+
+func (j *job) update(f func(view *jobView)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f(&j.view)
+}
+
+// Write adds the lines of p to the log of the job, so the job can be the messages of a session.
+func (j *job) Write(p []byte) (int, error) {
+	j.update(func(view *jobView) {
+		for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+			view.Log = append(view.Log, line)
+		}
+		if len(view.Log) > maxJobLogLines {
+			view.Log = view.Log[len(view.Log)-maxJobLogLines:]
+		}
+	})
+	return len(p), nil
+}
+
+func (j *job) Report(event vods.Event) {
+	j.update(func(view *jobView) {
+		switch event.Kind {
+		case vods.CandidateTried:
+			view.Progress.UrlsTried++
+		case vods.CandidateFailed:
+			view.Progress.UrlsInvalid++
+		case vods.SegmentChecked:
+			view.Progress.SegmentsChecked = event.Done
+			view.Progress.SegmentsTotal = event.Total
+		}
+	})
+}
+
+// jobVideos returns the streams of a lookup or download job.
+func jobVideos(request *jobRequest) ([]vods.VideoData, error) {
+	if isAbsent(request.Video) == isAbsent(request.Videos) {
+		return nil, errors.New("a lookup or download job needs either video or videos")
+	}
+	data := request.Videos
+	if !isAbsent(request.Video) {
+		data = append(append([]byte("["), request.Video...), ']')
+		if request.Format == vods.FeedSullyGnome {
+			data = append(append([]byte(`{"data":`), data...), '}')
+		}
+	}
+	videos, rowErrs, err := vods.ParseFeed(request.Format, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rowErrs) > 0 {
+		return nil, errors.New(fmt.Sprint(len(rowErrs), " invalid streams, the first is ", rowErrs[0]))
+	}
+	if len(videos) == 0 {
+		return nil, errors.New("the job has no streams")
+	}
+	return videos, nil
+}
+
+// isAbsent reports whether a field of a request was left out or is null.
+func isAbsent(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+// jobFiles returns the playlists of a validate job. They must be in the output directory.
+func jobFiles(ctx *cli.Context, request *jobRequest) ([]string, error) {
+	files := []string{}
+	outputDir, err := filepath.Abs(ctx.String("output-dir"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range request.Files {
+		path := filepath.Clean(file)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(outputDir, path)
+		}
+		rel, err := filepath.Rel(outputDir, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || !strings.HasSuffix(path, ".m3u8") {
+			return nil, errors.New(fmt.Sprint("file ", file, " is not a playlist in the output directory"))
+		}
+		files = append(files, path)
+	}
+	if len(request.VideoIds) > 0 {
+		catalog, err := vods.LoadCatalog(catalogPath(ctx))
+		if err != nil {
+			return nil, err
+		}
+		for _, videoId := range request.VideoIds {
+			entry, ok := catalog.Entries[videoId]
+			if !ok || entry.File == "" {
+				return nil, errors.New(fmt.Sprint("video id ", videoId, " has no playlist in the catalog"))
+			}
+			files = append(files, entry.File)
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("a validate job needs files or videoIds")
+	}
+	return files, nil
+}
+
+// jobFlags are the flags that a job can set, defaulting to the flags of the server.
+func (server *jobServer) jobFlags(request *jobRequest) (*flag.FlagSet, error) {
+	set := flag.NewFlagSet("job", flag.ContinueOnError)
+	set.String("filter-invalid", server.ctx.String("filter-invalid"), "")
+	set.Int("sample", server.ctx.Int("sample"), "")
+	set.Bool("verify", server.ctx.Bool("verify"), "")
+	set.String("check", "auto", "")
+	values := map[string]string{}
+	if request.FilterInvalid != nil {
+		values["filter-invalid"] = *request.FilterInvalid
+	}
+	if request.Sample != nil {
+		values["sample"] = strconv.Itoa(*request.Sample)
+	}
+	if request.Verify != nil {
+		values["verify"] = strconv.FormatBool(*request.Verify)
+	}
+	if request.Check != nil {
+		values["check"] = *request.Check
+	}
+	for name, value := range values {
+		if err := set.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range []string{"filter-invalid", "check"} {
+		if _, err := parseFilterInvalid(set.Lookup(name).Value.String()); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// A jobServer runs the jobs of a bounded queue with a fixed number of workers.
+type jobServer struct {
+	ctx      *cli.Context
+	session  *session
+	download []string // the download command of download jobs
+	queue    chan *job
+	keep     int // number of finished jobs that are kept
+	mu       sync.Mutex
+	jobs     map[string]*job
+	order    []string // ids of the jobs, the oldest first
+	nextId   int
+}
+
+var errQueueFull = errors.New("the job queue is full")
+
+func (server *jobServer) submit(request *jobRequest) (*job, error) {
+	j := &job{request: request}
+	var err error
+	switch request.Type {
+	case jobLookup, jobDownload:
+		if request.Type == jobDownload && len(server.download) == 0 {
+			return nil, errors.New("the server has no --download command")
+		}
+		if _, err := sourceProfileOfFeed(request.Format); err != nil {
+			return nil, err
+		}
+		j.videos, err = jobVideos(request)
+		j.view.Total = len(j.videos)
+	case jobValidate:
+		j.files, err = jobFiles(server.ctx, request)
+		j.view.Total = len(j.files)
+	default:
+		err = errors.New(fmt.Sprint("job type ", request.Type, " is not ", jobLookup, ", ", jobValidate, " or ", jobDownload))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if j.flags, err = server.jobFlags(request); err != nil {
+		return nil, err
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.nextId++
+	j.view.Id = strconv.Itoa(server.nextId)
+	j.view.Type = request.Type
+	j.view.Status = jobQueued
+	j.view.CreatedAt = time.Now()
+	j.view.Results = []jobResult{}
+	j.view.Log = []string{}
+	j.ctx, j.cancel = context.WithCancel(server.ctx.Context)
+	select {
+	case server.queue <- j:
+	default:
+		j.cancel()
+		return nil, errQueueFull
+	}
+	server.jobs[j.view.Id] = j
+	server.order = append(server.order, j.view.Id)
+	server.prune()
+	return j, nil
+}
+
+// prune forgets the oldest finished jobs beyond the number that are kept.
+func (server *jobServer) prune() {
+	numFinished := 0
+	for _, id := range server.order {
+		if status := server.jobs[id].snapshot().Status; status == jobDone || status == jobCanceled {
+			numFinished++
+		}
+	}
+	order := []string{}
+	for _, id := range server.order {
+		status := server.jobs[id].snapshot().Status
+		if numFinished > server.keep && (status == jobDone || status == jobCanceled) {
+			delete(server.jobs, id)
+			numFinished--
+			continue
+		}
+		order = append(order, id)
+	}
+	server.order = order
+}
+
+func (server *jobServer) job(id string) (*job, bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	j, ok := server.jobs[id]
+	return j, ok
+}
+
+// list returns the jobs, the most recent first.
+func (server *jobServer) list() []jobView {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	views := []jobView{}
+	for _, id := range server.order {
+		views = append(views, server.jobs[id].snapshot())
+	}
+	sort.SliceStable(views, func(i, j int) bool {
+		return views[i].CreatedAt.After(views[j].CreatedAt)
+	})
+	return views
+}
+
+// cancel cancels a job. A queued job is skipped by the workers.
+func (server *jobServer) cancel(j *job) {
+	j.cancel()
+	j.update(func(view *jobView) {
+		if view.Status == jobQueued {
+			now := time.Now()
+			view.Status = jobCanceled
+			view.FinishedAt = &now
+		}
+	})
+}
+
+func (server *jobServer) work() {
+	for {
+		select {
+		case <-server.ctx.Context.Done():
+			return
+		case j := <-server.queue:
+			server.run(j)
+		}
+	}
+}
+
+func (server *jobServer) run(j *job) {
+	defer j.cancel()
+	if j.ctx.Err() != nil {
+		server.cancel(j)
+		return
+	}
+	jobCtx := cli.NewContext(server.ctx.App, j.flags, server.ctx)
+	jobCtx.Context = j.ctx
+	s := server.session.forJob(jobCtx, j, j)
+	j.update(func(view *jobView) {
+		now := time.Now()
+		view.Status = jobRunning
+		view.StartedAt = &now
+	})
+	profile, _ := sourceProfileOfFeed(j.request.Format)
+	for _, videoData := range j.videos {
+		videoData := videoData
+		record, err := lookupVod(profile, &videoData, s)
+		if j.ctx.Err() != nil {
+			break
+		}
+		result := jobResult{lookupRecord: record}
+		if err != nil {
+			s.println(fmt.Sprint("Failed to resolve ", videoData.StreamerName, " ", videoData.VideoId, ": ", err))
+		}
+		if err == nil && j.request.Type == jobDownload {
+			if err := runDownload(s, server.download, record.File, record.Streamer, record.VideoId); err != nil {
+				result.DownloadError = err.Error()
+				s.println(fmt.Sprint("Failed to download ", record.File, ": ", err))
+			} else {
+				result.Downloaded = true
+				s.fireHooks(newHookEvent(vods.HookDownloadComplete, record.PlaylistMetadata, record.File, result))
+			}
+		}
+		j.finished(result, err != nil || result.DownloadError != "")
+	}
+	for _, path := range j.files {
+		record, err := refreshPlaylist(path, s)
+		if j.ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			record.Status = refreshFailed
+			record.setError(err)
+		}
+		record.print(s)
+		s.fireRefreshHooks(record)
+		j.finished(jobResult{lookupRecord: record.lookupRecord, Status: record.Status}, err != nil)
+	}
+	j.update(func(view *jobView) {
+		now := time.Now()
+		view.FinishedAt = &now
+		view.Status = jobDone
+		if j.ctx.Err() != nil {
+			view.Status = jobCanceled
+		}
+	})
+}
+
+func (j *job) finished(result jobResult, failed bool) {
+	j.update(func(view *jobView) {
+		view.Results = append(view.Results, result)
+		view.Completed++
+		if failed {
+			view.Failed++
+		}
+		view.Progress = jobProgress{}
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/auoie/goVods/vods"
+	"github.com/urfave/cli/v2"
+)
+
+// lintRecord is a line of lint with --output json.
+type lintRecord struct {
+	File string `json:"file"`
+	vods.LintIssue
+}
+
+var lintCommand = &cli.Command{
+	Name:      "lint",
+	Usage:     "Check .m3u8 files for HLS spec violations and unusual Twitch playlists",
+	ArgsUsage: "<file.m3u8>...",
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() == 0 {
+			return errors.New("no files to lint")
+		}
+		s, err := newSession(ctx)
+		if err != nil {
+			return err
+		}
+		defer s.close()
+		numErrors := 0
+		for _, file := range ctx.Args().Slice() {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			for _, issue := range vods.LintMediaPlaylist(data) {
+				if issue.Severity == vods.LintError {
+					numErrors++
+				}
+				if s.jsonOutput {
+					s.writeJson(lintRecord{File: file, LintIssue: issue})
+				} else {
+					s.println(fmt.Sprint(file, ": ", issue))
+				}
+			}
+		}
+		if numErrors > 0 {
+			return errors.New(fmt.Sprint(numErrors, " errors found"))
+		}
+		return nil
+	},
+}
@@ -8,10 +8,12 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/auoie/goVods/vods"
@@ -19,82 +21,370 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
-func writeMediaPlaylist(mediapl *m3u8.MediaPlaylist, dpi *vods.ValidDwpResponse) error {
-	videoData := dpi.Dwp.GetVideoData()
-	directoryPath := filepath.Join("Downloads", videoData.StreamerName)
-	if err := os.MkdirAll(directoryPath, os.ModePerm); err != nil {
-		return err
+// playlistPath is where the playlist of dpi is written, following --output-dir and --name-template.
+func playlistPath(ctx *cli.Context, mediapl *m3u8.MediaPlaylist, dpi *vods.ValidDwpResponse) (string, error) {
+	fields := vods.NewNameFields(dpi.Dwp, vods.GetMediaPlaylistDuration(mediapl))
+	name, err := vods.ExpandNameTemplate(ctx.String("name-template"), fields)
+	if err != nil {
+		return "", err
+	}
+	if ctx.Bool("no-sanitize") {
+		name = filepath.FromSlash(name)
+	} else {
+		name = vods.SanitizePath(name)
+	}
+	return filepath.Join(ctx.String("output-dir"), name+".m3u8"), nil
+}
+
+// writeMediaPlaylist returns the path of the written file, or - if it was written to stdout.
+func writeMediaPlaylist(mediapl *m3u8.MediaPlaylist, dpi *vods.ValidDwpResponse, s *session) (string, error) {
+	if s.stdoutPlaylist {
+		s.outputMu.Lock()
+		defer s.outputMu.Unlock()
+		_, err := io.Copy(os.Stdout, vods.EncodeMediaPlaylist(mediapl))
+		return "-", err
+	}
+	filePath, err := playlistPath(s.ctx, mediapl, dpi)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return "", err
 	}
-	roundedDuration := vods.GetMediaPlaylistDuration(mediapl).Truncate(time.Second)
-	filePath := filepath.Join(directoryPath, fmt.Sprint(videoData, "_", roundedDuration, ".m3u8"))
 	out, err := os.Create(filePath)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer out.Close()
-	_, err = io.Copy(out, mediapl.Encode())
+	_, err = io.Copy(out, vods.EncodeMediaPlaylist(mediapl))
 	if err != nil {
-		return err
+		return "", err
 	}
-	return nil
+	return filePath, nil
+}
+
+func getValidDwp(ctx context.Context, domains []string, offsets []int, videoData *vods.VideoData, planner *vods.SearchPlanner, client *http.Client) (*vods.ValidDwpResponse, error) {
+	videoPaths := videoData.GetRegisteredVideoPaths(offsets)
+	return planner.GetFirstValidDwp(ctx, vods.NewDomainWithPathsList(domains, videoPaths), client)
+}
+
+// A sourceProfile describes how the start times of a tracker relate to the times in the url paths.
+type sourceProfile struct {
+	name    string
+	seconds int // the time in the url path is within this many seconds after the provided time
 }
 
-func makeRobustClient() *http.Client {
-	timeout := 10 * time.Second
-	dialer := &net.Dialer{
-		Timeout: timeout,
+var (
+	twitchTrackerProfile = sourceProfile{name: "twitchtracker", seconds: 1}
+	streamsChartsProfile = sourceProfile{name: "streamscharts", seconds: 60}
+	sullyGnomeProfile    = sourceProfile{name: "sullygnome", seconds: 1}
+	stdinProfile         = sourceProfile{name: "stdin", seconds: 1}
+)
+
+func offsetsPath(ctx *cli.Context) string {
+	return filepath.Join(ctx.String("data-dir"), "offsets.json")
+}
+
+func mainHelper(profile sourceProfile, videoData *vods.VideoData, s *session) error {
+	_, err := lookupVod(profile, videoData, s)
+	return err
+}
+
+// lookupVod finds and writes the playlist of videoData. The record has the written file.
+func lookupVod(profile sourceProfile, videoData *vods.VideoData, s *session) (record *lookupRecord, err error) {
+	ctx := s.ctx
+	record = newLookupRecord(videoData, profile.name)
+	defer func() { s.emitRecord(record, err) }()
+	stats, err := vods.LoadOffsetStats(offsetsPath(ctx))
+	if err != nil {
+		return record, err
+	}
+	// some m3u8 file names use a time that is 1 second minus the provided time
+	offsets := stats.Histogram(profile.name).Order(vods.OffsetRange(-1, profile.seconds-1))
+	s.warmConnections()
+	dwpAndBody, err := getValidDwp(ctx.Context, vods.DOMAINS, offsets, videoData, s.planner, s.client)
+	finishProgress(s.reporter)
+	if err != nil {
+		return record, err
 	}
-	return &http.Client{
-		Timeout:   timeout,
-		Transport: &http.Transport{DialContext: dialer.DialContext},
+	if err := recordOffset(ctx, profile, videoData, dwpAndBody.Dwp.GetVideoData()); err != nil {
+		s.println(fmt.Sprint("Failed to record offset: ", err))
 	}
+	return record, processValidDwp(dwpAndBody, s, record)
 }
 
-func getValidDwp(ctx context.Context, domains []string, seconds int, videoData *vods.VideoData, client *http.Client) (*vods.ValidDwpResponse, error) {
-	domainWithPathsList := videoData.GetDomainWithPathsList(domains, seconds, true)
-	dwpAndBody, err := vods.GetFirstValidDwp(ctx, domainWithPathsList, client)
-	if err == nil {
-		return dwpAndBody, nil
-	}
-	// very rarely, a stream will use the seconds of the time rather than the unix time in the m3u8 file name
-	domainWithPathsList = videoData.GetDomainWithPathsList(domains, seconds, false)
-	dwpAndBody, err = vods.GetFirstValidDwp(ctx, domainWithPathsList, client)
-	if err == nil {
-		return dwpAndBody, nil
+// stateMu serializes the updates of the files in the data directory, because the jobs of the server run concurrently.
+var stateMu sync.Mutex
+
+func recordOffset(ctx *cli.Context, profile sourceProfile, provided *vods.VideoData, found *vods.VideoData) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	stats, err := vods.LoadOffsetStats(offsetsPath(ctx))
+	if err != nil {
+		return err
 	}
-	return nil, err
+	stats.Record(profile.name, provided.Time, found.Time)
+	return stats.Save(offsetsPath(ctx))
 }
 
-func mainHelper(seconds int, videoData *vods.VideoData, ctx *cli.Context) error {
-	videoData = videoData.WithOffset(-1) // some m3u8 file names use a time that is 1 second minus the provided time
-	client := makeRobustClient()
-	dwpAndBody, err := getValidDwp(ctx.Context, vods.DOMAINS, seconds+1, videoData, client)
+func processValidDwp(dwpAndBody *vods.ValidDwpResponse, s *session, record *lookupRecord) error {
+	ctx := s.ctx
+	record.SetDwp(dwpAndBody.Dwp, time.Now())
+	s.println(fmt.Sprint("Found valid url ", dwpAndBody.Dwp.GetIndexDvrUrl(), " with path scheme ", dwpAndBody.Dwp.Path.Scheme.Name()))
+	if err := recordAnchor(ctx, dwpAndBody.Dwp.GetVideoData()); err != nil {
+		s.println(fmt.Sprint("Failed to record anchor: ", err))
+	}
+	mediapl, err := preparePlaylist(dwpAndBody, s, record.PlaylistMetadata, ctx.String("filter-invalid"))
 	if err != nil {
 		return err
 	}
-	fmt.Println(fmt.Sprint("Found valid url ", dwpAndBody.Dwp.GetIndexDvrUrl()))
-	mediapl, err := vods.DecodeMediaPlaylistFilterNilSegments(dwpAndBody.Body, true)
+	filePath, err := writeMediaPlaylist(mediapl, dwpAndBody, s)
 	if err != nil {
 		return err
 	}
+	record.File = filePath
+	if filePath == "-" {
+		recordVod(s, record.PlaylistMetadata, "", true)
+		return nil
+	}
+	if ctx.Bool("save-raw") {
+		record.RawFile = vods.RawPlaylistPath(filePath)
+		if err := os.WriteFile(record.RawFile, dwpAndBody.Body, 0644); err != nil {
+			return err
+		}
+	}
+	if err := record.Save(vods.SidecarPath(filePath)); err != nil {
+		return err
+	}
+	// the catalog points at the sidecar, so it is only recorded once the sidecar is written
+	recordVod(s, record.PlaylistMetadata, filePath, true)
+	return nil
+}
+
+// preparePlaylist decodes the playlist of dwpAndBody, mutes it, makes its urls explicit,
+// and if concurrency is set, removes the invalid segments. It fills in the segments of metadata.
+func preparePlaylist(dwpAndBody *vods.ValidDwpResponse, s *session, metadata *vods.PlaylistMetadata, concurrency string) (*m3u8.MediaPlaylist, error) {
+	ctx := s.ctx
+	mediapl, warning, err := vods.DecodeMediaPlaylistLenient(dwpAndBody.Body)
+	if err != nil {
+		return nil, err
+	}
+	if warning != nil {
+		s.println(fmt.Sprint("Warning: decoded the playlist without strict checks: ", warning))
+		metadata.Warnings = append(metadata.Warnings, warning.Error())
+	}
 	vods.MuteMediaSegments(mediapl)
 	dwpAndBody.Dwp.MakePathsExplicit(mediapl)
-	checkInvalidConcurrent := ctx.Int("filter-invalid")
-	if checkInvalidConcurrent > 0 {
+	metadata.Segments = len(mediapl.Segments)
+	metadata.MutedRanges = vods.MutedRanges(mediapl)
+	validator, err := makeSegmentValidator(ctx, concurrency, s.reporter)
+	if err != nil {
+		return nil, err
+	}
+	if validator != nil {
 		numTotalSegments := len(mediapl.Segments)
-		mediapl, err = vods.GetMediaPlaylistWithValidSegments(mediapl, checkInvalidConcurrent, client)
+		results, err := vods.ValidateSegments(ctx.Context, mediapl, validator, s.client, s.prober)
+		finishProgress(s.reporter)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		mediapl, err = vods.GetMediaPlaylistWithValidSegments(mediapl, results)
+		if err != nil {
+			return nil, err
 		}
+		metadata.MissingRanges = vods.MissingRanges(results)
 		numValidSegments := len(mediapl.Segments)
-		fmt.Println(fmt.Sprint(numValidSegments, " valid segments out of ", numTotalSegments))
+		numMissingSegments := numTotalSegments - numValidSegments
+		metadata.ValidSegments = &numValidSegments
+		metadata.MissingSegments = &numMissingSegments
+		s.println(fmt.Sprint(numValidSegments, " valid segments out of ", numTotalSegments))
 		if numValidSegments == 0 {
-			return errors.New("0 valid segments found")
+			return nil, errNoValidSegments
 		}
 	}
-	return writeMediaPlaylist(mediapl, dwpAndBody)
+	metadata.MutedSegments = vods.CountMutedSegments(mediapl)
+	metadata.DurationSeconds = vods.GetMediaPlaylistDuration(mediapl).Seconds()
+	return mediapl, nil
+}
+
+var samplingFlags = []cli.Flag{
+	&cli.IntFlag{
+		Name:  "sample",
+		Usage: "only check every Nth segment and bisect to find where missing ranges start and end",
+	},
+	&cli.BoolFlag{
+		Name:  "verify",
+		Usage: "with --sample, check the segments that were assumed valid or invalid afterwards",
+	},
+}
+
+var validationFlags = append([]cli.Flag{
+	&cli.StringFlag{
+		Name:  "filter-invalid",
+		Usage: "Filter out all of the invalid segments in the m3u8 file with concurrency level, or 'auto' to adapt the concurrency",
+	},
+}, samplingFlags...)
+
+// makeSegmentValidator returns nil if segments shouldn't be checked. concurrency is a number or auto.
+func makeSegmentValidator(ctx *cli.Context, concurrency string, reporter vods.Reporter) (vods.SegmentValidator, error) {
+	limiter, err := parseFilterInvalid(concurrency)
+	if err != nil || limiter == nil {
+		return nil, err
+	}
+	if stride := ctx.Int("sample"); stride > 0 {
+		return &vods.SamplingValidator{Limiter: limiter, Stride: stride, Verify: ctx.Bool("verify"), Reporter: reporter}, nil
+	}
+	return &vods.ExhaustiveValidator{Limiter: limiter, Reporter: reporter}, nil
+}
+
+// parseFilterInvalid returns nil if segments shouldn't be filtered.
+func parseFilterInvalid(value string) (vods.Limiter, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if value == "auto" {
+		return vods.NewAdaptiveLimiter(vods.DefaultAdaptiveOptions), nil
+	}
+	concurrent, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, errors.New(fmt.Sprint("concurrency ", value, " is not a number or 'auto'"))
+	}
+	if concurrent <= 0 {
+		return nil, nil
+	}
+	return vods.NewFixedLimiter(concurrent), nil
+}
+
+func defaultDataDir() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ".govods"
+	}
+	return filepath.Join(configDir, "govods")
+}
+
+func anchorsPath(ctx *cli.Context) string {
+	return filepath.Join(ctx.String("data-dir"), "anchors.json")
+}
+
+func recordAnchor(ctx *cli.Context, videoData *vods.VideoData) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	model, err := vods.LoadAnchorModel(anchorsPath(ctx))
+	if err != nil {
+		return err
+	}
+	if err := model.Add(videoData); err != nil {
+		return err
+	}
+	return model.Save(anchorsPath(ctx))
+}
+
+func idHelper(streamer string, videoid string, s *session) (err error) {
+	ctx := s.ctx
+	record := newLookupRecord(&vods.VideoData{StreamerName: streamer, VideoId: videoid}, "")
+	defer func() { s.emitRecord(record, err) }()
+	model, err := vods.LoadAnchorModel(anchorsPath(ctx))
+	if err != nil {
+		return err
+	}
+	window, err := model.Estimate(videoid)
+	if err != nil {
+		return err
+	}
+	search := &vods.WindowSearch{
+		StreamerName:   streamer,
+		VideoId:        videoid,
+		Window:         window,
+		Domains:        vods.DOMAINS,
+		ChunkSeconds:   ctx.Int("chunk"),
+		MaxRequests:    ctx.Int("budget"),
+		CheckpointPath: filepath.Join(ctx.String("data-dir"), "checkpoints", fmt.Sprint(streamer, "_", videoid, ".json")),
+		Planner:        s.planner,
+	}
+	s.println(fmt.Sprint("Searching between ", window.Start.Format(time.RFC3339), " and ", window.End.Format(time.RFC3339)))
+	s.warmConnections()
+	dwpAndBody, err := search.Run(ctx.Context, s.client)
+	finishProgress(s.reporter)
+	if err != nil {
+		return err
+	}
+	return processValidDwp(dwpAndBody, s, record)
 }
 
+// globalFlags are the flags of every command.
+var globalFlags = append([]cli.Flag{
+	&cli.StringFlag{
+		Name:  "data-dir",
+		Usage: "directory for state that persists between runs",
+		Value: defaultDataDir(),
+	},
+	&cli.IntFlag{
+		Name:  "concurrency",
+		Usage: "maximum number of requests in flight while searching for a url, 0 for no limit",
+		Value: vods.DefaultSearchPlanner.Concurrency,
+	},
+	&cli.IntFlag{
+		Name:  "per-domain",
+		Usage: "maximum number of requests in flight to a single domain while searching for a url, 0 for no limit",
+		Value: vods.DefaultSearchPlanner.PerDomain,
+	},
+	&cli.IntFlag{
+		Name:  "max-requests",
+		Usage: "maximum number of requests while searching for a single url, 0 for no limit",
+	},
+	&cli.StringFlag{
+		Name:  "probe",
+		Usage: "how to check that urls exist: head, range (GET of the first byte) or get. Domains that reject a method fall back to the next one",
+		Value: vods.ProbeHead.String(),
+	},
+	&cli.IntFlag{
+		Name:  "max-attempts",
+		Usage: "maximum number of attempts of a request that was rate limited or failed to connect",
+		Value: vods.DefaultRetryPolicy.MaxAttempts,
+	},
+	&cli.BoolFlag{
+		Name:  "conn-stats",
+		Usage: "print how many connections were dialed and reused",
+	},
+	&cli.StringFlag{
+		Name:  "output",
+		Usage: "text, or json to write a line of JSON per lookup to stdout",
+		Value: "text",
+	},
+	&cli.StringFlag{
+		Name:  "output-dir",
+		Usage: "directory of the written playlists",
+		Value: "Downloads",
+	},
+	&cli.StringFlag{
+		Name:  "name-template",
+		Usage: "path of a playlist in the output directory without the .m3u8 extension, using {streamer}, {videoid}, {start}, {start:layout} with a Go time layout, {duration} and {domain}",
+		Value: vods.DefaultNameTemplate,
+	},
+	&cli.BoolFlag{
+		Name:  "no-sanitize",
+		Usage: "keep characters in playlist paths that are invalid on some filesystems, such as ':'",
+	},
+	&cli.BoolFlag{
+		Name:  "save-raw",
+		Usage: "also write the original playlist next to each playlist with the extension .raw.m3u8",
+	},
+	&cli.BoolFlag{
+		Name:  "stdout",
+		Usage: "write playlists to stdout instead of the output directory, e.g. to pipe into a player",
+	},
+	&cli.StringFlag{
+		Name:  "hooks",
+		Usage: "hooks file of webhooks and commands run on events (default: hooks.json in the data directory)",
+	},
+	&cli.StringFlag{
+		Name:  "progress",
+		Usage: "how to show progress on stderr: bar, json (a line per event) or quiet",
+		Value: "bar",
+	},
+}, clientFlags...)
+
 type StdinJson []struct {
 	StartTime    time.Time `json:"time"`
 	StreamID     string    `json:"id"`
@@ -103,6 +393,8 @@ type StdinJson []struct {
 
 func main() {
 	app := &cli.App{
+		Version: version(),
+		Flags:   globalFlags,
 		Commands: []*cli.Command{
 			{
 				Name:  "stdin",
@@ -119,11 +411,16 @@ func main() {
 					if err != nil {
 						return err
 					}
+					s, err := newSession(ctx)
+					if err != nil {
+						return err
+					}
+					defer s.close()
 					for _, datum := range jsonData {
 						videoData := vods.VideoData{StreamerName: datum.StreamerName, VideoId: datum.StreamID, Time: datum.StartTime}
-						err = mainHelper(1, &videoData, ctx)
+						err = mainHelper(stdinProfile, &videoData, s)
 						if err != nil {
-							fmt.Println(err)
+							s.println(err)
 						}
 					}
 					return nil
@@ -132,7 +429,7 @@ func main() {
 			{
 				Name:  "tt-manual-get-m3u8",
 				Usage: "Using twitchtracker.com data, get an .m3u8 file which can be viewed in a media player.",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:     "streamer",
 						Usage:    "twitch streamer name",
@@ -148,11 +445,7 @@ func main() {
 						Usage:    "stream UTC start time in the format '2006-01-02 15:04:05' (year-month-day hour:minute:second)",
 						Required: true,
 					},
-					&cli.IntFlag{
-						Name:  "filter-invalid",
-						Usage: "Filter out all of the invalid segments in the m3u8 file with concurrency level",
-					},
-				},
+				}, validationFlags...),
 				Action: func(ctx *cli.Context) error {
 					streamer := ctx.String("streamer")
 					videoid := ctx.String("videoid")
@@ -162,13 +455,18 @@ func main() {
 					if err != nil {
 						return err
 					}
-					return mainHelper(1, &videoData, ctx)
+					s, err := newSession(ctx)
+					if err != nil {
+						return err
+					}
+					defer s.close()
+					return mainHelper(twitchTrackerProfile, &videoData, s)
 				},
 			},
 			{
 				Name:  "sc-manual-get-m3u8",
 				Usage: "Using streamscharts.com data, get an .m3u8 file which can be viewed in a media player.",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:     "streamer",
 						Usage:    "twitch streamer name",
@@ -184,11 +482,7 @@ func main() {
 						Usage:    "stream UTC start time in the format '02-01-2006 15:04' (day-month-year hour:minute)",
 						Required: true,
 					},
-					&cli.IntFlag{
-						Name:  "filter-invalid",
-						Usage: "Filter out all of the invalid segments in the m3u8 file with concurrency level",
-					},
-				},
+				}, validationFlags...),
 				Action: func(ctx *cli.Context) error {
 					streamer := ctx.String("streamer")
 					videoid := ctx.String("videoid")
@@ -198,13 +492,18 @@ func main() {
 					if err != nil {
 						return err
 					}
-					return mainHelper(60, &videoData, ctx)
+					s, err := newSession(ctx)
+					if err != nil {
+						return err
+					}
+					defer s.close()
+					return mainHelper(streamsChartsProfile, &videoData, s)
 				},
 			},
 			{
 				Name:  "sg-manual-get-m3u8",
 				Usage: "Using sullygnome.com data, get an .m3u8 file which can be viewed in a media player.",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:     "streamer",
 						Usage:    "twitch streamer name",
@@ -220,11 +519,7 @@ func main() {
 						Usage:    "stream UTC start time in the format '2006-01-02T15:04:05Z' (year-month-dayThour:minute:secondZ)",
 						Required: true,
 					},
-					&cli.IntFlag{
-						Name:  "filter-invalid",
-						Usage: "Filter out all of the invalid segments in the m3u8 file with concurrency level",
-					},
-				},
+				}, validationFlags...),
 				Action: func(ctx *cli.Context) error {
 					streamer := ctx.String("streamer")
 					videoid := ctx.String("videoid")
@@ -234,12 +529,63 @@ func main() {
 					if err != nil {
 						return err
 					}
-					return mainHelper(1, &videoData, ctx)
+					s, err := newSession(ctx)
+					if err != nil {
+						return err
+					}
+					defer s.close()
+					return mainHelper(sullyGnomeProfile, &videoData, s)
+				},
+			},
+			{
+				Name:  "id-get-m3u8",
+				Usage: "Using only a video id, estimate the start time from known anchors and search for an .m3u8 file.",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:     "streamer",
+						Usage:    "twitch streamer name",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "videoid",
+						Usage:    "twitch video id",
+						Required: true,
+					},
+					&cli.IntFlag{
+						Name:  "budget",
+						Usage: "maximum number of requests for this run, 0 for no limit. The search resumes from a checkpoint on the next run",
+						Value: 100000,
+					},
+					&cli.IntFlag{
+						Name:  "chunk",
+						Usage: "number of seconds searched concurrently between checkpoints",
+						Value: 60,
+					},
+				}, validationFlags...),
+				Action: func(ctx *cli.Context) error {
+					s, err := newSession(ctx)
+					if err != nil {
+						return err
+					}
+					defer s.close()
+					return idHelper(ctx.String("streamer"), ctx.String("videoid"), s)
 				},
 			},
+			lintCommand,
+			inspectCommand,
+			refreshCommand,
+			expiringCommand,
+			listCommand,
+			searchCommand,
+			catalogImportCommand,
+			watchCommand,
+			hooksTestCommand,
+			serverCommand,
 		},
 	}
-	err := app.Run(os.Args)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err := app.RunContext(ctx, os.Args)
 	if err != nil {
 		log.Fatal(err)
 	}
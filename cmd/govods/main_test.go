@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+	"github.com/grafov/m3u8"
+	"github.com/urfave/cli/v2"
+)
+
+func assertEqual[T comparable](t testing.TB, got, want T) {
+	t.Helper()
+	if got != want {
+		t.Fatalf(`got %v want %v`, got, want)
+	}
+}
+
+// newTestContext returns the context of a command with the global flags and flags, parsed from args.
+// The data and output directories are temporary, and a single request is in flight at a time,
+// so a search tries the domain of a test server before the real domains.
+func newTestContext(t *testing.T, flags []cli.Flag, args ...string) *cli.Context {
+	t.Helper()
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range append(append([]cli.Flag{}, globalFlags...), flags...) {
+		if err := f.Apply(set); err != nil {
+			t.Fatalf(err.Error())
+		}
+	}
+	dir := t.TempDir()
+	defaults := []string{
+		"--data-dir", filepath.Join(dir, "data"),
+		"--output-dir", filepath.Join(dir, "output"),
+		"--concurrency", "1",
+		"--progress", "quiet",
+	}
+	if err := set.Parse(append(defaults, args...)); err != nil {
+		t.Fatalf(err.Error())
+	}
+	ctx := cli.NewContext(&cli.App{Name: "govods"}, set, nil)
+	var cancel context.CancelFunc
+	ctx.Context, cancel = context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func newTestSession(t *testing.T, ctx *cli.Context) *session {
+	t.Helper()
+	s, err := newSession(ctx)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	s.messages = io.Discard
+	t.Cleanup(s.hookWorker.close)
+	return s
+}
+
+var testVideoData = &vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038929, 0)}
+
+// A vodServer serves the playlist of testVideoData with numSegments segments named 0.ts, 1.ts, ...
+// The segments that are missing return 403.
+type vodServer struct {
+	*httptest.Server
+	numSegments int
+	mu          sync.Mutex
+	missing     map[string]bool
+}
+
+func newVodServer(t *testing.T, numSegments int) *vodServer {
+	server := &vodServer{numSegments: numSegments, missing: map[string]bool{}}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serve))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (server *vodServer) dwp() *vods.DomainWithPath {
+	return &vods.DomainWithPath{Domain: server.URL + "/", Path: testVideoData.GetVideoPath(vods.UnixPathScheme)}
+}
+
+func (server *vodServer) setMissing(segments ...string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.missing = map[string]bool{}
+	for _, segment := range segments {
+		server.missing[segment] = true
+	}
+}
+
+func (server *vodServer) playlist() string {
+	builder := strings.Builder{}
+	builder.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:10\n#EXT-X-PLAYLIST-TYPE:EVENT\n")
+	for i := 0; i < server.numSegments; i++ {
+		builder.WriteString(fmt.Sprint("#EXTINF:10.000,\n", i, ".ts\n"))
+	}
+	builder.WriteString("#EXT-X-ENDLIST\n")
+	return builder.String()
+}
+
+func (server *vodServer) serve(w http.ResponseWriter, r *http.Request) {
+	prefix := "/" + server.dwp().Path.UrlPath + "/chunked/"
+	name := strings.TrimPrefix(r.URL.Path, prefix)
+	server.mu.Lock()
+	missing := server.missing[name]
+	server.mu.Unlock()
+	switch {
+	case !strings.HasPrefix(r.URL.Path, prefix) || missing:
+		w.WriteHeader(http.StatusForbidden)
+	case name == "index-dvr.m3u8":
+		w.Write([]byte(server.playlist()))
+	default:
+		w.Write([]byte("segment"))
+	}
+}
+
+// writeTestPlaylist writes the playlist of server to name in the output directory, without the segments that are missing,
+// and its sidecar. It returns the path of the playlist.
+func writeTestPlaylist(t *testing.T, ctx *cli.Context, server *vodServer, name string, missing ...string) string {
+	t.Helper()
+	mediapl, _, err := vods.DecodeMediaPlaylistLenient([]byte(server.playlist()))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	dwp := server.dwp()
+	results := []vods.SegmentResult{}
+	for i, segment := range mediapl.Segments {
+		valid := true
+		for _, name := range missing {
+			valid = valid && segment.URI != name
+		}
+		results = append(results, vods.SegmentResult{Index: i, Valid: valid})
+	}
+	dwp.MakePathsExplicit(mediapl)
+	mediapl, err = vods.GetMediaPlaylistWithValidSegments(mediapl, results)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	path := filepath.Join(ctx.String("output-dir"), name)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := os.WriteFile(path, mediapl.Encode().Bytes(), 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+	metadata := vods.NewPlaylistMetadata(testVideoData)
+	metadata.SetDwp(dwp, time.Now())
+	if err := metadata.Save(vods.SidecarPath(path)); err != nil {
+		t.Fatalf(err.Error())
+	}
+	return path
+}
+
+func decodeTestPlaylist(t *testing.T, path string) *m3u8.MediaPlaylist {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	mediapl, _, err := vods.DecodeMediaPlaylistLenient(data)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	return mediapl
+}
+
+// segmentNames returns the names of the segments of the playlist at path.
+func segmentNames(t *testing.T, path string) []string {
+	t.Helper()
+	names := []string{}
+	for _, segment := range decodeTestPlaylist(t, path).Segments {
+		names = append(names, segment.URI[strings.LastIndex(segment.URI, "/")+1:])
+	}
+	return names
+}
+
+func TestProcessValidDwpRecordsAfterSidecar(t *testing.T) {
+	server := newVodServer(t, 2)
+	ctx := newTestContext(t, validationFlags, "--save-raw")
+	s := newTestSession(t, ctx)
+	response := &vods.ValidDwpResponse{Dwp: server.dwp(), Body: []byte(server.playlist())}
+	record := newLookupRecord(testVideoData, "test")
+	if err := processValidDwp(response, s, record); err != nil {
+		t.Fatalf(err.Error())
+	}
+	catalog, err := vods.LoadCatalog(catalogPath(ctx))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(catalog.Entries), 1)
+	assertEqual(t, catalog.Entries[testVideoData.VideoId].SidecarFile, vods.SidecarPath(record.File))
+
+	// a lookup that can't write the raw playlist isn't added to the catalog
+	if err := os.Remove(catalogPath(ctx)); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := os.Remove(record.RawFile); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := os.Mkdir(record.RawFile, os.ModePerm); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := processValidDwp(response, s, newLookupRecord(testVideoData, "test")); err == nil {
+		t.Fatalf("wrote the raw playlist over a directory")
+	}
+	catalog, err = vods.LoadCatalog(catalogPath(ctx))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(catalog.Entries), 0)
+}
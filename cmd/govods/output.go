@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/auoie/goVods/vods"
+)
+
+// A lookupRecord is the result of looking up a single vod, written as a line of JSON with --output json.
+type lookupRecord struct {
+	*vods.PlaylistMetadata
+	File      string `json:"file,omitempty"`
+	RawFile   string `json:"rawFile,omitempty"` // the original playlist with --save-raw
+	Error     string `json:"error,omitempty"`
+	ErrorType string `json:"errorType,omitempty"`
+}
+
+func newLookupRecord(videoData *vods.VideoData, source string) *lookupRecord {
+	metadata := vods.NewPlaylistMetadata(videoData)
+	metadata.Version = version()
+	metadata.Source = source
+	return &lookupRecord{PlaylistMetadata: metadata}
+}
+
+func (record *lookupRecord) setError(err error) {
+	if err == nil {
+		return
+	}
+	record.Error = err.Error()
+	record.ErrorType = errorType(err)
+}
+
+var errNoValidSegments = errors.New("0 valid segments found")
+
+// errorType is a stable name for the kind of err, so scripts don't depend on the messages.
+func errorType(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	case errors.Is(err, vods.ErrRequestLimit):
+		return "request-limit"
+	case errors.Is(err, vods.ErrBudgetExhausted):
+		return "budget-exhausted"
+	case errors.Is(err, vods.ErrWindowExhausted):
+		return "window-exhausted"
+	case errors.Is(err, vods.ErrNotEnoughAnchors), errors.Is(err, vods.ErrNonMonotonic):
+		return "no-estimate"
+	case errors.Is(err, vods.ErrNoCandidates):
+		return "no-candidates"
+	case errors.Is(err, errNoValidSegments):
+		return "no-valid-segments"
+	case errors.Is(err, vods.ErrRateLimited):
+		return "rate-limited"
+	case errors.Is(err, vods.ErrTransport):
+		return "transport"
+	case errors.Is(err, vods.ErrNotFound), errors.Is(err, vods.ErrForbidden):
+		return "not-found"
+	}
+	return "other"
+}
+
+// emitRecord fires the hooks of a lookup that finished with err, and writes its record if the output is json.
+func (s *session) emitRecord(record *lookupRecord, err error) {
+	record.setError(err)
+	s.fireLookupHooks(record, err)
+	if !s.jsonOutput {
+		return
+	}
+	s.writeJson(record)
+}
+
+// writeJson writes v as a line of JSON to stdout.
+func (s *session) writeJson(v interface{}) {
+	s.outputMu.Lock()
+	defer s.outputMu.Unlock()
+	json.NewEncoder(os.Stdout).Encode(v)
+}
+
+// println writes a human readable message. With --output json or --stdout, stdout is
+// reserved for records or playlists, so it goes to stderr.
+func (s *session) println(a ...interface{}) {
+	if s.messages != nil {
+		fmt.Fprintln(s.messages, a...)
+		return
+	}
+	if s.jsonOutput || s.stdoutPlaylist {
+		fmt.Fprintln(os.Stderr, a...)
+		return
+	}
+	fmt.Println(a...)
+}
+
+func parseOutput(output string) (bool, error) {
+	switch output {
+	case "text":
+		return false, nil
+	case "json":
+		return true, nil
+	}
+	return false, errors.New(fmt.Sprint("output ", output, " is not text or json"))
+}
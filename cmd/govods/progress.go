@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/auoie/goVods/vods"
+)
+
+const clearLine = "\033[2K"
+
+// newReporter returns the reporter of a --progress mode, writing to w.
+func newReporter(mode string, w io.Writer) (vods.Reporter, error) {
+	switch mode {
+	case "bar":
+		return &barReporter{w: w}, nil
+	case "json":
+		return &jsonReporter{encoder: json.NewEncoder(w)}, nil
+	case "quiet":
+		return vods.NopReporter, nil
+	}
+	return nil, errors.New(fmt.Sprint("progress ", mode, " is not bar, json or quiet"))
+}
+
+// finishProgress ends the progress line of reporters that draw one.
+func finishProgress(reporter vods.Reporter) {
+	if finisher, ok := reporter.(interface{ finish() }); ok {
+		finisher.finish()
+	}
+}
+
+// A barReporter redraws a single terminal line with the number of urls tried
+// or a bar of the segments checked.
+type barReporter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	tried  int
+	failed int
+	drawn  bool
+}
+
+const barWidth = 30
+
+func (reporter *barReporter) Report(event vods.Event) {
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+	switch event.Kind {
+	case vods.CandidateTried:
+		reporter.tried++
+		reporter.draw(fmt.Sprint("Tried ", reporter.tried, " urls, ", reporter.failed, " invalid"))
+	case vods.CandidateFailed:
+		reporter.failed++
+		reporter.draw(fmt.Sprint("Tried ", reporter.tried, " urls, ", reporter.failed, " invalid"))
+	case vods.PlaylistFound:
+		reporter.tried = 0
+		reporter.failed = 0
+		reporter.finishLocked()
+	case vods.SegmentChecked:
+		filled := 0
+		if event.Total > 0 {
+			filled = barWidth * event.Done / event.Total
+		}
+		bar := strings.Repeat("#", filled) + strings.Repeat(".", barWidth-filled)
+		reporter.draw(fmt.Sprint("[", bar, "] ", event.Done, "/", event.Total, " segments with concurrency ", event.Limit))
+	}
+}
+
+func (reporter *barReporter) draw(line string) {
+	fmt.Fprint(reporter.w, clearLine, "\r", line)
+	reporter.drawn = true
+}
+
+func (reporter *barReporter) finish() {
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+	reporter.finishLocked()
+}
+
+func (reporter *barReporter) finishLocked() {
+	if reporter.drawn {
+		fmt.Fprintln(reporter.w)
+		reporter.drawn = false
+	}
+	reporter.tried = 0
+	reporter.failed = 0
+}
+
+// progressRecord is a line of --progress json.
+type progressRecord struct {
+	Event      vods.EventKind `json:"event"`
+	Url        string         `json:"url,omitempty"`
+	PathScheme string         `json:"pathScheme,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Segment    *int           `json:"segment,omitempty"`
+	Valid      *bool          `json:"valid,omitempty"`
+	StatusCode int            `json:"statusCode,omitempty"`
+	Done       int            `json:"done,omitempty"`
+	Total      int            `json:"total,omitempty"`
+	Limit      int            `json:"limit,omitempty"`
+}
+
+// A jsonReporter writes every event as a line of JSON.
+type jsonReporter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func (reporter *jsonReporter) Report(event vods.Event) {
+	record := progressRecord{Event: event.Kind, Done: event.Done, Total: event.Total, Limit: event.Limit}
+	if event.Dwp != nil {
+		record.Url = event.Dwp.GetIndexDvrUrl()
+		if event.Dwp.Path.Scheme != nil {
+			record.PathScheme = event.Dwp.Path.Scheme.Name()
+		}
+	}
+	if event.Err != nil {
+		record.Error = event.Err.Error()
+	}
+	if segment := event.Segment; segment != nil {
+		record.Url = segment.Url
+		record.Segment = &segment.Index
+		record.Valid = &segment.Valid
+		record.StatusCode = segment.StatusCode
+		if segment.Err != nil {
+			record.Error = segment.Err.Error()
+		}
+	}
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+	reporter.encoder.Encode(record)
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+	"github.com/grafov/m3u8"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	refreshUnchanged = "unchanged" // every segment that was in the playlist is still available
+	refreshUpdated   = "updated"   // the playlist was rewritten because segments changed
+	refreshVanished  = "vanished"  // the playlist or all of its segments are gone
+	refreshFailed    = "failed"    // the playlist couldn't be checked, e.g. because of rate limits
+)
+
+// A refreshRecord is the result of refreshing a playlist, written as a line of JSON with --output json.
+type refreshRecord struct {
+	*lookupRecord
+	Status string `json:"status"`
+}
+
+// findPlaylists returns the playlists in paths, which can be files or directories. Raw playlists are skipped.
+func findPlaylists(paths []string) ([]string, error) {
+	playlists := []string{}
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && strings.HasSuffix(path, ".m3u8") && !strings.HasSuffix(path, ".raw.m3u8") {
+				playlists = append(playlists, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return playlists, nil
+}
+
+// loadRefreshMetadata returns the metadata of the playlist at path from its sidecar,
+// or if it has none, from the first of its segment urls that has the video data.
+func loadRefreshMetadata(path string, mediapl *m3u8.MediaPlaylist) (*vods.PlaylistMetadata, error) {
+	metadata, err := vods.LoadPlaylistMetadata(vods.SidecarPath(path))
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return metadata, err
+	}
+	for _, segment := range mediapl.Segments {
+		dwp, err := vods.UrlToDomainWithPath(segment.URI)
+		if err != nil {
+			continue
+		}
+		videoData := dwp.GetVideoData()
+		metadata = vods.NewPlaylistMetadata(&vods.VideoData{StreamerName: videoData.StreamerName, VideoId: videoData.VideoId})
+		metadata.SetDwp(dwp, time.Time{})
+		metadata.ResolvedAt = nil
+		return metadata, nil
+	}
+	return nil, errors.New("playlist has no sidecar and no segment url with the video data")
+}
+
+// refreshPlaylist finds the playlist at path again, checks its segments, and rewrites it if they changed.
+func refreshPlaylist(path string, s *session) (*refreshRecord, error) {
+	ctx := s.ctx
+	record := &refreshRecord{lookupRecord: &lookupRecord{File: path}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return record, err
+	}
+	current, _, err := vods.DecodeMediaPlaylistLenient(data)
+	if err != nil {
+		return record, err
+	}
+	metadata, err := loadRefreshMetadata(path, current)
+	if err != nil {
+		return record, err
+	}
+	record.PlaylistMetadata = metadata
+	dwp, err := metadata.DomainWithPath()
+	if err != nil {
+		return record, err
+	}
+	// the domain it was found at is tried first
+	domains := []string{dwp.Domain}
+	for _, domain := range vods.DOMAINS {
+		if domain != dwp.Domain {
+			domains = append(domains, domain)
+		}
+	}
+	dwpAndBody, err := s.planner.GetFirstValidDwp(ctx.Context, vods.NewDomainWithPathsList(domains, []*vods.VideoPath{dwp.Path}), s.client)
+	finishProgress(s.reporter)
+	if vods.IsWrongCandidate(err) {
+		return record, markVanished(record, path, s)
+	}
+	if err != nil {
+		return record, err
+	}
+	refreshed := &vods.PlaylistMetadata{
+		Version:  version(),
+		Streamer: metadata.Streamer,
+		VideoId:  metadata.VideoId,
+		Source:   metadata.Source,
+		Time:     metadata.Time,
+	}
+	refreshed.SetDwp(dwpAndBody.Dwp, time.Now())
+	record.PlaylistMetadata = refreshed
+	mediapl, err := preparePlaylist(dwpAndBody, s, refreshed, ctx.String("check"))
+	if errors.Is(err, errNoValidSegments) {
+		record.PlaylistMetadata = metadata
+		return record, markVanished(record, path, s)
+	}
+	if err != nil {
+		return record, err
+	}
+	if limiter, _ := parseFilterInvalid(ctx.String("check")); limiter == nil {
+		// without checks, the segments that were removed as missing stay removed
+		mediapl, err = keepKnownSegments(current, mediapl)
+		if err != nil {
+			return record, err
+		}
+		if len(mediapl.Segments) == 0 {
+			record.PlaylistMetadata = metadata
+			return record, markVanished(record, path, s)
+		}
+		refreshed.MutedSegments = vods.CountMutedSegments(mediapl)
+		refreshed.DurationSeconds = vods.GetMediaPlaylistDuration(mediapl).Seconds()
+	}
+	recordVod(s, refreshed, path, true)
+	if vods.SameSegments(current, mediapl) {
+		record.Status = refreshUnchanged
+		return record, nil
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, vods.EncodeMediaPlaylist(mediapl).Bytes(), 0644); err != nil {
+		return record, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return record, err
+	}
+	record.Status = refreshUpdated
+	return record, refreshed.Save(vods.SidecarPath(path))
+}
+
+// segmentKey identifies a segment across domains by the part of its url after the path of the video.
+func segmentKey(uri string) string {
+	if i := strings.LastIndex(uri, "/chunked/"); i >= 0 {
+		return uri[i+len("/chunked/"):]
+	}
+	return uri
+}
+
+// keepKnownSegments returns mediapl with only the segments that are also in current.
+func keepKnownSegments(current *m3u8.MediaPlaylist, mediapl *m3u8.MediaPlaylist) (*m3u8.MediaPlaylist, error) {
+	known := map[string]bool{}
+	for _, segment := range current.Segments {
+		known[segmentKey(segment.URI)] = true
+	}
+	results := []vods.SegmentResult{}
+	for i, segment := range mediapl.Segments {
+		results = append(results, vods.SegmentResult{Index: i, Url: segment.URI, Valid: known[segmentKey(segment.URI)]})
+	}
+	return vods.GetMediaPlaylistWithValidSegments(mediapl, results)
+}
+
+func markVanished(record *refreshRecord, path string, s *session) error {
+	recordVod(s, record.PlaylistMetadata, path, false)
+	record.Status = refreshVanished
+	if record.VanishedAt == nil {
+		now := time.Now()
+		record.VanishedAt = &now
+	}
+	return record.Save(vods.SidecarPath(path))
+}
+
+func (record *refreshRecord) print(s *session) {
+	switch record.Status {
+	case refreshFailed:
+		s.println(fmt.Sprint("Failed to refresh ", record.File, ": ", record.Error))
+	case refreshVanished:
+		s.println(fmt.Sprint("Vanished ", record.File))
+	case refreshUpdated:
+		numSegments := record.Segments
+		if record.ValidSegments != nil {
+			numSegments = *record.ValidSegments
+		}
+		s.println(fmt.Sprint("Updated ", record.File, " with ", numSegments, " segments"))
+	default:
+		s.println(fmt.Sprint("Unchanged ", record.File))
+	}
+}
+
+func refreshAll(paths []string, s *session) error {
+	playlists, err := findPlaylists(paths)
+	if err != nil {
+		return err
+	}
+	counts := map[string]int{}
+	for _, path := range playlists {
+		record, err := refreshPlaylist(path, s)
+		if s.ctx.Context.Err() != nil {
+			return s.ctx.Context.Err()
+		}
+		if err != nil {
+			record.Status = refreshFailed
+			record.setError(err)
+		}
+		counts[record.Status]++
+		if s.jsonOutput {
+			s.writeJson(record)
+		} else {
+			record.print(s)
+		}
+		s.fireRefreshHooks(record)
+	}
+	s.println(fmt.Sprint("Refreshed ", len(playlists), " playlists: ", counts[refreshUnchanged], " unchanged, ", counts[refreshUpdated], " updated, ", counts[refreshVanished], " vanished, ", counts[refreshFailed], " failed"))
+	return nil
+}
+
+var refreshCommand = &cli.Command{
+	Name:      "refresh",
+	Usage:     "Find written playlists again, check which segments are still available, and rewrite the playlists that changed",
+	ArgsUsage: "[file.m3u8|directory]... (default: the output directory)",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  "check",
+			Usage: "check the segments with concurrency level, 'auto' to adapt the concurrency, or 0 to only drop the segments that are no longer in the playlist",
+			Value: "auto",
+		},
+		&cli.DurationFlag{
+			Name:  "every",
+			Usage: "refresh again after this long until interrupted, e.g. 24h",
+		},
+	}, samplingFlags...),
+	Action: func(ctx *cli.Context) error {
+		s, err := newSession(ctx)
+		if err != nil {
+			return err
+		}
+		defer s.close()
+		paths := ctx.Args().Slice()
+		if len(paths) == 0 {
+			paths = []string{ctx.String("output-dir")}
+		}
+		for {
+			err := refreshAll(paths, s)
+			if ctx.Context.Err() != nil && ctx.Duration("every") > 0 {
+				return nil
+			}
+			if err != nil || ctx.Duration("every") <= 0 {
+				return err
+			}
+			select {
+			case <-ctx.Context.Done():
+				return nil
+			case <-time.After(ctx.Duration("every")):
+			}
+		}
+	},
+}
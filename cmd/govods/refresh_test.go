@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func refreshTestPlaylist(t *testing.T, path string, check string, args ...string) *refreshRecord {
+	t.Helper()
+	ctx := newTestContext(t, refreshCommand.Flags, append([]string{"--check", check}, args...)...)
+	record, err := refreshPlaylist(path, newTestSession(t, ctx))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	return record
+}
+
+func TestRefreshPlaylist(t *testing.T) {
+	server := newVodServer(t, 4)
+	ctx := newTestContext(t, refreshCommand.Flags)
+	path := writeTestPlaylist(t, ctx, server, "vod.m3u8", "2.ts")
+
+	// without checks, the segment that was removed as missing stays removed
+	record := refreshTestPlaylist(t, path, "0")
+	assertEqual(t, record.Status, refreshUnchanged)
+	assertEqual(t, strings.Join(segmentNames(t, path), " "), "0.ts 1.ts 3.ts")
+
+	server.setMissing("1.ts")
+	record = refreshTestPlaylist(t, path, "2")
+	assertEqual(t, record.Status, refreshUpdated)
+	assertEqual(t, *record.MissingSegments, 1)
+	assertEqual(t, strings.Join(segmentNames(t, path), " "), "0.ts 2.ts 3.ts")
+
+	record = refreshTestPlaylist(t, path, "2", "--sample", "2", "--verify")
+	assertEqual(t, record.Status, refreshUnchanged)
+
+	server.setMissing("0.ts", "1.ts", "2.ts", "3.ts")
+	record = refreshTestPlaylist(t, path, "2")
+	assertEqual(t, record.Status, refreshVanished)
+	assertEqual(t, record.VanishedAt != nil, true)
+	assertEqual(t, strings.Join(segmentNames(t, path), " "), "0.ts 2.ts 3.ts")
+}
+
+func TestKeepKnownSegments(t *testing.T) {
+	server := newVodServer(t, 4)
+	ctx := newTestContext(t, refreshCommand.Flags)
+	current := writeTestPlaylist(t, ctx, server, "current.m3u8", "0.ts", "3.ts")
+	// segments of the upstream playlist are kept by name, even if they were found at another domain
+	other := newVodServer(t, 4)
+	upstream := writeTestPlaylist(t, ctx, other, "upstream.m3u8", "2.ts")
+	currentpl := decodeTestPlaylist(t, current)
+	mediapl, err := keepKnownSegments(currentpl, decodeTestPlaylist(t, upstream))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(mediapl.Segments), 1)
+	assertEqual(t, strings.HasPrefix(mediapl.Segments[0].URI, other.URL), true)
+	assertEqual(t, segmentKey(mediapl.Segments[0].URI), "1.ts")
+	assertEqual(t, segmentKey("1.ts"), "1.ts")
+}
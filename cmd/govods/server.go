@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const maxJobRequestBytes = 16 << 20
+
+func writeJsonResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeErrorResponse(w http.ResponseWriter, status int, err error) {
+	writeJsonResponse(w, status, map[string]string{"error": err.Error()})
+}
+
+// ServeHTTP serves the job api:
+//
+//	POST   /jobs                    submit a job
+//	GET    /jobs                    list the jobs, the most recent first
+//	GET    /jobs/{id}               get a job
+//	DELETE /jobs/{id}               cancel a job
+//	GET    /jobs/{id}/playlists/{n} get the playlist of the nth result of a job, from 0
+//	GET    /health                  get the number of queued jobs
+func (server *jobServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if token := server.ctx.String("token"); token != "" {
+		if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			writeErrorResponse(w, http.StatusUnauthorized, errors.New("missing or wrong bearer token"))
+			return
+		}
+	}
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "health" && req.Method == http.MethodGet:
+		writeJsonResponse(w, http.StatusOK, map[string]int{"queued": len(server.queue), "queueSize": cap(server.queue)})
+	case len(parts) == 1 && parts[0] == "jobs" && req.Method == http.MethodGet:
+		writeJsonResponse(w, http.StatusOK, server.list())
+	case len(parts) == 1 && parts[0] == "jobs" && req.Method == http.MethodPost:
+		server.serveSubmit(w, req)
+	case len(parts) >= 2 && parts[0] == "jobs":
+		j, ok := server.job(parts[1])
+		switch {
+		case !ok:
+			writeErrorResponse(w, http.StatusNotFound, errors.New(fmt.Sprint("there is no job ", parts[1])))
+		case len(parts) == 2 && req.Method == http.MethodGet:
+			writeJsonResponse(w, http.StatusOK, j.snapshot())
+		case len(parts) == 2 && req.Method == http.MethodDelete:
+			server.cancel(j)
+			writeJsonResponse(w, http.StatusOK, j.snapshot())
+		case len(parts) == 4 && parts[2] == "playlists" && req.Method == http.MethodGet:
+			servePlaylist(w, req, j, parts[3])
+		default:
+			writeErrorResponse(w, http.StatusNotFound, errors.New(fmt.Sprint("no route for ", req.Method, " ", req.URL.Path)))
+		}
+	default:
+		writeErrorResponse(w, http.StatusNotFound, errors.New(fmt.Sprint("no route for ", req.Method, " ", req.URL.Path)))
+	}
+}
+
+func (server *jobServer) serveSubmit(w http.ResponseWriter, req *http.Request) {
+	request := &jobRequest{}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxJobRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(request); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, errors.New(fmt.Sprint("invalid job: ", err)))
+		return
+	}
+	j, err := server.submit(request)
+	if errors.Is(err, errQueueFull) {
+		w.Header().Set("Retry-After", "60")
+		writeErrorResponse(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+j.snapshot().Id)
+	writeJsonResponse(w, http.StatusAccepted, j.snapshot())
+}
+
+func servePlaylist(w http.ResponseWriter, req *http.Request, j *job, index string) {
+	results := j.snapshot().Results
+	n, err := strconv.Atoi(index)
+	if err != nil || n < 0 || n >= len(results) {
+		writeErrorResponse(w, http.StatusNotFound, errors.New(fmt.Sprint("the job has no result ", index)))
+		return
+	}
+	file := results[n].File
+	if file == "" {
+		writeErrorResponse(w, http.StatusNotFound, errors.New(fmt.Sprint("result ", index, " has no playlist")))
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	http.ServeFile(w, req, file)
+}
+
+var serverCommand = &cli.Command{
+	Name:  "server",
+	Usage: "Run a REST api to submit lookup, validate and download jobs, query and cancel them, and fetch their playlists",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  "listen",
+			Usage: "address to listen on",
+			Value: "127.0.0.1:8080",
+		},
+		&cli.IntFlag{
+			Name:  "workers",
+			Usage: "number of jobs that run at the same time",
+			Value: 2,
+		},
+		&cli.IntFlag{
+			Name:  "queue",
+			Usage: "number of jobs that can wait to run, more are rejected with 503",
+			Value: 100,
+		},
+		&cli.IntFlag{
+			Name:  "keep",
+			Usage: "number of finished jobs that are kept to be queried",
+			Value: 1000,
+		},
+		&cli.StringFlag{
+			Name:  "download",
+			Usage: "command of download jobs, run after the playlist is written, replacing {file}, {streamer} and {videoid}, e.g. 'ffmpeg -protocol_whitelist file,https,tls,tcp -i {file} -c copy {videoid}.mp4'",
+		},
+		&cli.StringFlag{
+			Name:    "token",
+			Usage:   "require requests to have the header 'Authorization: Bearer {token}'",
+			EnvVars: []string{"GOVODS_TOKEN"},
+		},
+	}, validationFlags...),
+	Action: func(ctx *cli.Context) error {
+		if ctx.Int("workers") <= 0 || ctx.Int("queue") < 0 {
+			return errors.New("--workers must be positive and --queue must not be negative")
+		}
+		s, err := newSession(ctx)
+		if err != nil {
+			return err
+		}
+		if s.stdoutPlaylist {
+			return errors.New("server writes playlists to the output directory, not --stdout")
+		}
+		if s.jsonOutput {
+			return errors.New("server returns the records of jobs from its api, not --output json")
+		}
+		defer s.close()
+		server := &jobServer{
+			ctx:      ctx,
+			session:  s,
+			download: strings.Fields(ctx.String("download")),
+			queue:    make(chan *job, ctx.Int("queue")),
+			keep:     ctx.Int("keep"),
+			jobs:     map[string]*job{},
+		}
+		for i := 0; i < ctx.Int("workers"); i++ {
+			go server.work()
+		}
+		httpServer := &http.Server{Addr: ctx.String("listen"), Handler: server, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			<-ctx.Context.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			httpServer.Shutdown(shutdownCtx)
+		}()
+		fmt.Fprintln(os.Stderr, "Listening on", ctx.String("listen"))
+		err = httpServer.ListenAndServe()
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	},
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestJobServer returns a job server with workers and a queue of size queue, and a test server of its api.
+func newTestJobServer(t *testing.T, workers int, queue int, args ...string) (*jobServer, *httptest.Server) {
+	t.Helper()
+	ctx := newTestContext(t, serverCommand.Flags, args...)
+	server := &jobServer{
+		ctx:     ctx,
+		session: newTestSession(t, ctx),
+		queue:   make(chan *job, queue),
+		keep:    ctx.Int("keep"),
+		jobs:    map[string]*job{},
+	}
+	for i := 0; i < workers; i++ {
+		go server.work()
+	}
+	api := httptest.NewServer(server)
+	t.Cleanup(api.Close)
+	return server, api
+}
+
+// apiRequest makes a request to the api and returns the response and its body.
+func apiRequest(t *testing.T, api *httptest.Server, method string, path string, body string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, api.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	resp, err := api.Client().Do(req)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	return resp, data
+}
+
+// A testJobView is a jobView that can be decoded, since the lookup record of a jobResult is unexported.
+type testJobView struct {
+	jobView
+	Results []struct {
+		File   string `json:"file"`
+		Status string `json:"status"`
+	} `json:"results"`
+}
+
+func getJob(t *testing.T, api *httptest.Server, id string) testJobView {
+	t.Helper()
+	resp, data := apiRequest(t, api, http.MethodGet, "/jobs/"+id, "")
+	assertEqual(t, resp.StatusCode, http.StatusOK)
+	view := testJobView{}
+	if err := json.Unmarshal(data, &view); err != nil {
+		t.Fatalf(err.Error())
+	}
+	return view
+}
+
+// waitForJob returns the job once it is done or canceled.
+func waitForJob(t *testing.T, api *httptest.Server, id string) testJobView {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		view := getJob(t, api, id)
+		if view.Status == jobDone || view.Status == jobCanceled {
+			return view
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %v is still %v", id, view.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerValidateJob(t *testing.T) {
+	vodServer := newVodServer(t, 4)
+	server, api := newTestJobServer(t, 1, 10)
+	writeTestPlaylist(t, server.ctx, vodServer, filepath.Join("gmhikaru", "vod.m3u8"))
+	vodServer.setMissing("2.ts")
+	resp, _ := apiRequest(t, api, http.MethodPost, "/jobs", `{"type": "validate", "files": ["gmhikaru/vod.m3u8"], "check": "2"}`)
+	assertEqual(t, resp.StatusCode, http.StatusAccepted)
+	assertEqual(t, resp.Header.Get("Location"), "/jobs/1")
+	view := waitForJob(t, api, "1")
+	assertEqual(t, view.Status, jobDone)
+	assertEqual(t, view.Total, 1)
+	assertEqual(t, view.Completed, 1)
+	assertEqual(t, view.Failed, 0)
+	assertEqual(t, len(view.Results), 1)
+	assertEqual(t, view.Results[0].Status, refreshUpdated)
+
+	resp, data := apiRequest(t, api, http.MethodGet, "/jobs", "")
+	assertEqual(t, resp.StatusCode, http.StatusOK)
+	views := []testJobView{}
+	if err := json.Unmarshal(data, &views); err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(views), 1)
+	assertEqual(t, views[0].Id, "1")
+
+	resp, data = apiRequest(t, api, http.MethodGet, "/jobs/1/playlists/0", "")
+	assertEqual(t, resp.StatusCode, http.StatusOK)
+	assertEqual(t, resp.Header.Get("Content-Type"), "application/vnd.apple.mpegurl")
+	file := filepath.Join(t.TempDir(), "playlist.m3u8")
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, strings.Join(segmentNames(t, file), " "), "0.ts 1.ts 3.ts")
+
+	resp, _ = apiRequest(t, api, http.MethodGet, "/jobs/1/playlists/1", "")
+	assertEqual(t, resp.StatusCode, http.StatusNotFound)
+	resp, _ = apiRequest(t, api, http.MethodGet, "/jobs/2", "")
+	assertEqual(t, resp.StatusCode, http.StatusNotFound)
+}
+
+func TestServerRejectsInvalidJobs(t *testing.T) {
+	_, api := newTestJobServer(t, 0, 10)
+	for _, body := range []string{
+		`{"type": "transcode", "files": ["a.m3u8"]}`,
+		`{"type": "validate", "files": ["a.m3u8"], "priority": 1}`,
+		`{"type": "validate", "files": ["a.m3u8"], "check": "often"}`,
+		`{"type": "validate", "files": ["../a.m3u8"]}`,
+		`{"type": "validate"}`,
+		`{"type": "lookup"}`,
+		`{"type": "lookup", "video": null, "videos": null}`,
+		`{"type": "lookup", "videos": [{"time": "2022-09-24T17:02:09Z", "id": "47198535725", "name": "gmhikaru"}, {"time": "2022-09-24T17:02:09Z", "name": "gmhikaru"}]}`,
+		`{"type": "download", "video": {"time": "2022-09-24T17:02:09Z", "id": "47198535725", "name": "gmhikaru"}}`,
+	} {
+		resp, _ := apiRequest(t, api, http.MethodPost, "/jobs", body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("got %v want %v for %v", resp.StatusCode, http.StatusBadRequest, body)
+		}
+	}
+	resp, data := apiRequest(t, api, http.MethodGet, "/jobs", "")
+	assertEqual(t, resp.StatusCode, http.StatusOK)
+	assertEqual(t, strings.TrimSpace(string(data)), "[]")
+}
+
+func TestJobVideosNull(t *testing.T) {
+	video := `{"time": "2022-09-24T17:02:09Z", "id": "47198535725", "name": "gmhikaru"}`
+	for _, request := range []*jobRequest{
+		{Video: json.RawMessage(video), Videos: json.RawMessage("null")},
+		{Video: json.RawMessage("null"), Videos: json.RawMessage("[" + video + "]")},
+	} {
+		videos, err := jobVideos(request)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		assertEqual(t, len(videos), 1)
+		assertEqual(t, videos[0].VideoId, "47198535725")
+	}
+	if _, err := jobVideos(&jobRequest{Video: json.RawMessage("null"), Videos: json.RawMessage("null")}); err == nil {
+		t.Fatalf("accepted a job without streams")
+	}
+}
+
+func TestServerRejectsJsonOutput(t *testing.T) {
+	ctx := newTestContext(t, serverCommand.Flags, "--output", "json")
+	if err := serverCommand.Action(ctx); err == nil || !strings.Contains(err.Error(), "--output json") {
+		t.Fatalf("got %v want an error about --output json", err)
+	}
+}
+
+func TestServerToken(t *testing.T) {
+	_, api := newTestJobServer(t, 0, 10, "--token", "secret")
+	resp, _ := apiRequest(t, api, http.MethodGet, "/health", "")
+	assertEqual(t, resp.StatusCode, http.StatusUnauthorized)
+	req, err := http.NewRequest(http.MethodGet, api.URL+"/health", nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = api.Client().Do(req)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	resp.Body.Close()
+	assertEqual(t, resp.StatusCode, http.StatusOK)
+}
+
+func TestServerQueueFull(t *testing.T) {
+	_, api := newTestJobServer(t, 0, 1)
+	job := `{"type": "validate", "files": ["a.m3u8"]}`
+	resp, _ := apiRequest(t, api, http.MethodPost, "/jobs", job)
+	assertEqual(t, resp.StatusCode, http.StatusAccepted)
+	resp, _ = apiRequest(t, api, http.MethodPost, "/jobs", job)
+	assertEqual(t, resp.StatusCode, http.StatusServiceUnavailable)
+	assertEqual(t, resp.Header.Get("Retry-After"), "60")
+	resp, data := apiRequest(t, api, http.MethodGet, "/health", "")
+	assertEqual(t, resp.StatusCode, http.StatusOK)
+	assertEqual(t, strings.TrimSpace(string(data)), `{"queueSize":1,"queued":1}`)
+}
+
+func TestServerCancelQueuedJob(t *testing.T) {
+	server, api := newTestJobServer(t, 0, 1)
+	job := `{"type": "validate", "files": ["a.m3u8"]}`
+	resp, _ := apiRequest(t, api, http.MethodPost, "/jobs", job)
+	assertEqual(t, resp.StatusCode, http.StatusAccepted)
+	resp, data := apiRequest(t, api, http.MethodDelete, "/jobs/1", "")
+	assertEqual(t, resp.StatusCode, http.StatusOK)
+	view := testJobView{}
+	if err := json.Unmarshal(data, &view); err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, view.Status, jobCanceled)
+	assertEqual(t, view.FinishedAt != nil, true)
+	// a worker skips the canceled job
+	go server.work()
+	view = waitForJob(t, api, "1")
+	assertEqual(t, view.Status, jobCanceled)
+	assertEqual(t, view.StartedAt == nil, true)
+	assertEqual(t, view.Completed, 0)
+	deadline := time.Now().Add(10 * time.Second)
+	for len(server.queue) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp, _ = apiRequest(t, api, http.MethodPost, "/jobs", job)
+	assertEqual(t, resp.StatusCode, http.StatusAccepted)
+}
+
+func TestJobFiles(t *testing.T) {
+	ctx := newTestContext(t, serverCommand.Flags)
+	outputDir, err := filepath.Abs(ctx.String("output-dir"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	tests := []struct {
+		file  string
+		valid bool
+	}{
+		{"a.m3u8", true},
+		{filepath.Join("gmhikaru", "a.m3u8"), true},
+		{filepath.Join(outputDir, "gmhikaru", "a.m3u8"), true},
+		{filepath.Join("gmhikaru", "..", "a.m3u8"), true},
+		{filepath.Join("..", "a.m3u8"), false},
+		{filepath.Join("gmhikaru", "..", "..", "a.m3u8"), false},
+		{filepath.Join(outputDir, "..", "a.m3u8"), false},
+		{filepath.Join(filepath.Dir(outputDir), "other", "a.m3u8"), false},
+		{outputDir + "-other" + string(filepath.Separator) + "a.m3u8", false},
+		{"a.json", false},
+	}
+	for _, test := range tests {
+		files, err := jobFiles(ctx, &jobRequest{Type: jobValidate, Files: []string{test.file}})
+		if (err == nil) != test.valid {
+			t.Fatalf("got error %v for %v want valid %v", err, test.file, test.valid)
+		}
+		if err == nil {
+			assertEqual(t, len(files), 1)
+			assertEqual(t, filepath.Dir(files[0]) == outputDir || strings.HasPrefix(files[0], outputDir+string(filepath.Separator)), true)
+		}
+	}
+}
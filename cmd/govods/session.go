@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/auoie/goVods/vods"
+	"github.com/urfave/cli/v2"
+)
+
+// A session holds what is shared by the lookups of a single command.
+type session struct {
+	ctx            *cli.Context
+	client         *http.Client
+	connStats      *vods.ConnStats
+	planner        *vods.SearchPlanner
+	prober         *vods.Prober
+	reporter       vods.Reporter
+	hooks          *vods.Hooks
+	hookWorker     *hookWorker
+	jsonOutput     bool
+	stdoutPlaylist bool      // playlists are written to stdout, so messages go to stderr
+	messages       io.Writer // if set, receives the messages instead of stdout or stderr
+	outputMu       sync.Mutex
+	warmOnce       sync.Once
+}
+
+func newSession(ctx *cli.Context) (*session, error) {
+	probeMethod, err := vods.ParseProbeMethod(ctx.String("probe"))
+	if err != nil {
+		return nil, err
+	}
+	prober := vods.NewProber(probeMethod)
+	jsonOutput, err := parseOutput(ctx.String("output"))
+	if err != nil {
+		return nil, err
+	}
+	stdoutPlaylist := ctx.Bool("stdout")
+	if stdoutPlaylist && jsonOutput {
+		return nil, errors.New("--stdout and --output json both write to stdout")
+	}
+	if _, err := vods.ExpandNameTemplate(ctx.String("name-template"), vods.NameFields{}); err != nil {
+		return nil, err
+	}
+	reporter, err := newReporter(ctx.String("progress"), os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	hooks, err := vods.LoadHooks(hooksPath(ctx))
+	if err != nil {
+		return nil, err
+	}
+	config, err := loadClientConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	options, err := config.toClientOptions(vods.DefaultClientOptions)
+	if err != nil {
+		return nil, err
+	}
+	options.Retry.MaxAttempts = ctx.Int("max-attempts")
+	client, connStats := vods.NewClient(options)
+	s := &session{
+		ctx:       ctx,
+		client:    client,
+		connStats: connStats,
+		planner: &vods.SearchPlanner{
+			Concurrency: ctx.Int("concurrency"),
+			PerDomain:   ctx.Int("per-domain"),
+			MaxRequests: ctx.Int("max-requests"),
+			Prober:      prober,
+			Reporter:    reporter,
+		},
+		prober:         prober,
+		reporter:       reporter,
+		hooks:          hooks,
+		jsonOutput:     jsonOutput,
+		stdoutPlaylist: stdoutPlaylist,
+	}
+	s.hookWorker = startHookWorker(s)
+	return s, nil
+}
+
+// warmConnections connects to every domain before the first search of the session.
+func (s *session) warmConnections() {
+	s.warmOnce.Do(func() {
+		vods.WarmConnections(s.ctx.Context, s.client, vods.DOMAINS)
+	})
+}
+
+func (s *session) printConnStats() {
+	if s.ctx.Bool("conn-stats") {
+		s.println(s.connStats.Snapshot())
+	}
+}
+
+// close waits for the hooks of the session and prints the connection stats with --conn-stats.
+func (s *session) close() {
+	s.hookWorker.close()
+	s.printConnStats()
+}
+
+// forJob returns a session for a job of the server that shares the client of s.
+// ctx has the flags and the context of the job.
+func (s *session) forJob(ctx *cli.Context, reporter vods.Reporter, messages io.Writer) *session {
+	planner := *s.planner
+	planner.Reporter = reporter
+	return &session{
+		ctx:        ctx,
+		client:     s.client,
+		connStats:  s.connStats,
+		planner:    &planner,
+		prober:     s.prober,
+		reporter:   reporter,
+		hooks:      s.hooks,
+		hookWorker: s.hookWorker,
+		messages:   messages,
+	}
+}
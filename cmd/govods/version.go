@@ -0,0 +1,17 @@
+package main
+
+import "runtime/debug"
+
+// buildVersion can be set with -ldflags "-X main.buildVersion=v1.2.3".
+var buildVersion = ""
+
+// version is the version of govods, from the build flags or the module version it was installed at.
+func version() string {
+	if buildVersion != "" {
+		return buildVersion
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+	"github.com/urfave/cli/v2"
+)
+
+// watchlistConfig is the config file of the watch command.
+type watchlistConfig struct {
+	Streamers   []string    `json:"streamers"` // only streams of these streamers, or every stream if empty
+	Feeds       []watchFeed `json:"feeds"`
+	Interval    string      `json:"interval"`    // how often the feeds are read, e.g. "5m"
+	RetryAfter  string      `json:"retryAfter"`  // how long until a stream that failed is tried again, e.g. "1h"
+	MaxAttempts int         `json:"maxAttempts"` // attempts of a stream before giving up, 0 for no limit
+	Download    []string    `json:"download"`    // command run after a playlist is written, e.g. ["yt-dlp", "{file}"]
+}
+
+// A watchFeed is where a scraper drops the exports of a tracker.
+type watchFeed struct {
+	Path   string `json:"path"`   // a file, or a directory whose files are all read
+	Format string `json:"format"` // govods (default), sullygnome or twitchtracker
+}
+
+type watchSettings struct {
+	config     *watchlistConfig
+	interval   time.Duration
+	retryAfter time.Duration
+	streamers  map[string]bool
+	statePath  string
+	rules      *vods.RetentionRules
+}
+
+func watchlistPath(ctx *cli.Context) string {
+	if path := ctx.String("watchlist"); path != "" {
+		return path
+	}
+	return filepath.Join(ctx.String("data-dir"), "watchlist.json")
+}
+
+func watchStatePath(ctx *cli.Context) string {
+	return filepath.Join(ctx.String("data-dir"), "watch.json")
+}
+
+func loadWatchSettings(ctx *cli.Context) (*watchSettings, error) {
+	path := watchlistPath(ctx)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &watchlistConfig{Interval: "5m", RetryAfter: "1h", MaxAttempts: 3}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, errors.New(fmt.Sprint("invalid watchlist ", path, ": ", err))
+	}
+	if len(config.Feeds) == 0 {
+		return nil, errors.New(fmt.Sprint("watchlist ", path, " has no feeds"))
+	}
+	for _, feed := range config.Feeds {
+		if _, err := sourceProfileOfFeed(feed.Format); err != nil {
+			return nil, err
+		}
+	}
+	settings := &watchSettings{config: config, streamers: map[string]bool{}, statePath: watchStatePath(ctx)}
+	if settings.interval, err = time.ParseDuration(config.Interval); err != nil {
+		return nil, errors.New(fmt.Sprint("invalid interval in watchlist ", path, ": ", err))
+	}
+	if settings.retryAfter, err = time.ParseDuration(config.RetryAfter); err != nil {
+		return nil, errors.New(fmt.Sprint("invalid retryAfter in watchlist ", path, ": ", err))
+	}
+	for _, streamer := range config.Streamers {
+		settings.streamers[strings.ToLower(streamer)] = true
+	}
+	if settings.rules, err = vods.LoadRetentionRules(retentionPath(ctx)); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func sourceProfileOfFeed(format string) (sourceProfile, error) {
+	switch format {
+	case vods.FeedGovods, "":
+		return stdinProfile, nil
+	case vods.FeedSullyGnome:
+		return sullyGnomeProfile, nil
+	case vods.FeedTwitchTracker:
+		return twitchTrackerProfile, nil
+	}
+	return sourceProfile{}, errors.New(fmt.Sprint("feed format ", format, " is not ", vods.FeedGovods, ", ", vods.FeedSullyGnome, " or ", vods.FeedTwitchTracker))
+}
+
+// feedFiles returns the path if it is a file, or the files directly in it if it is a directory.
+// Hidden files and files that are still being written (.tmp and .part) are skipped.
+func feedFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".tmp") || strings.HasSuffix(name, ".part") {
+			continue
+		}
+		files = append(files, filepath.Join(path, name))
+	}
+	return files, nil
+}
+
+// readFeeds adds the new streams of the watched streamers in the feeds to state, and returns how many were added.
+// Streams that are estimated to be deleted already are skipped. Files and rows that can't be read are reported and skipped.
+func (settings *watchSettings) readFeeds(state *vods.WatchState, s *session) int {
+	now := time.Now()
+	numFound := 0
+	for _, feed := range settings.config.Feeds {
+		files, err := feedFiles(feed.Path)
+		if err != nil {
+			s.println(fmt.Sprint("Failed to read feed ", feed.Path, ": ", err))
+			continue
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err == nil {
+				var videos []vods.VideoData
+				var rowErrs []vods.FeedRowError
+				videos, rowErrs, err = vods.ParseFeed(feed.Format, data)
+				for i := range videos {
+					numFound += settings.found(state, &videos[i], feed.Format, now)
+				}
+				for _, rowErr := range rowErrs {
+					s.println(fmt.Sprint("Skipped a stream of feed file ", file, ": ", rowErr))
+				}
+			}
+			if err != nil {
+				s.println(fmt.Sprint("Failed to read feed file ", file, ": ", err))
+			}
+		}
+	}
+	return numFound
+}
+
+func (settings *watchSettings) found(state *vods.WatchState, videoData *vods.VideoData, format string, now time.Time) int {
+	if len(settings.streamers) > 0 && !settings.streamers[strings.ToLower(videoData.StreamerName)] {
+		return 0
+	}
+	if videoData.Time.Add(settings.rules.Retention(videoData.StreamerName)).Before(now) {
+		return 0
+	}
+	if format == "" {
+		format = vods.FeedGovods
+	}
+	if !state.Found(videoData, format, now) {
+		return 0
+	}
+	return 1
+}
+
+// runDownload runs a download command for the playlist at file, replacing {file}, {streamer} and {videoid} in its arguments.
+func runDownload(s *session, download []string, file string, streamer string, videoId string) error {
+	replacer := strings.NewReplacer("{file}", file, "{streamer}", streamer, "{videoid}", videoId)
+	args := []string{}
+	for _, arg := range download {
+		args = append(args, replacer.Replace(arg))
+	}
+	s.println(fmt.Sprint("Running ", strings.Join(args, " ")))
+	command := exec.CommandContext(s.ctx.Context, args[0], args[1:]...)
+	if s.messages != nil {
+		command.Stdout = s.messages
+		command.Stderr = s.messages
+	} else {
+		command.Stdout = os.Stderr
+		command.Stderr = os.Stderr
+	}
+	return command.Run()
+}
+
+// poll reads the feeds and resolves the streams that are due. The state is saved after every stream.
+func (settings *watchSettings) poll(s *session) error {
+	state, err := vods.LoadWatchState(settings.statePath)
+	if err != nil {
+		return err
+	}
+	numFound := settings.readFeeds(state, s)
+	if err := state.Save(settings.statePath); err != nil {
+		return err
+	}
+	due := state.Due(settings.config.MaxAttempts, settings.retryAfter, time.Now())
+	s.println(fmt.Sprint("Found ", numFound, " new streams, ", len(due), " to resolve"))
+	for _, vod := range due {
+		profile, _ := sourceProfileOfFeed(vod.Source)
+		record, err := lookupVod(profile, vod.VideoData(), s)
+		if s.ctx.Context.Err() != nil {
+			return s.ctx.Context.Err()
+		}
+		state.Attempted(vod.VideoId, record.File, err, time.Now())
+		if err != nil {
+			s.println(fmt.Sprint("Failed to resolve ", vod.Streamer, " ", vod.VideoId, " (attempt ", vod.Attempts, "): ", err))
+		} else if len(settings.config.Download) > 0 {
+			vod.DownloadError = ""
+			if err := runDownload(s, settings.config.Download, vod.File, vod.Streamer, vod.VideoId); err != nil {
+				vod.DownloadError = err.Error()
+				s.println(fmt.Sprint("Failed to download ", vod.File, ": ", err))
+			}
+			vod.Downloaded = vod.DownloadError == ""
+			if vod.Downloaded {
+				s.fireHooks(newHookEvent(vods.HookDownloadComplete, record.PlaylistMetadata, vod.File, vod))
+			}
+		}
+		if err := state.Save(settings.statePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var watchCommand = &cli.Command{
+	Name:  "watch",
+	Usage: "Read the input feeds of a watchlist periodically and resolve the new streams of the watched streamers",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  "watchlist",
+			Usage: "path of the watchlist config (default: watchlist.json in the data directory)",
+		},
+		&cli.BoolFlag{
+			Name:  "once",
+			Usage: "read the feeds and resolve the new streams once instead of until interrupted",
+		},
+	}, validationFlags...),
+	Action: func(ctx *cli.Context) error {
+		settings, err := loadWatchSettings(ctx)
+		if err != nil {
+			return err
+		}
+		s, err := newSession(ctx)
+		if err != nil {
+			return err
+		}
+		if s.stdoutPlaylist {
+			return errors.New("watch writes playlists to the output directory, not --stdout")
+		}
+		defer s.close()
+		for {
+			err := settings.poll(s)
+			if ctx.Context.Err() != nil && !ctx.Bool("once") {
+				return nil
+			}
+			if err != nil || ctx.Bool("once") {
+				return err
+			}
+			select {
+			case <-ctx.Context.Done():
+				return nil
+			case <-time.After(settings.interval):
+			}
+		}
+	},
+}
@@ -3,7 +3,6 @@ module github.com/auoie/goVods
 go 1.19
 
 require (
-	github.com/auoie/first-nonerr v1.1.0
 	github.com/grafov/m3u8 v0.11.1
 	github.com/urfave/cli/v2 v2.23.7
 )
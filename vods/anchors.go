@@ -0,0 +1,177 @@
+package vods
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Anchors taken from vods that have been resolved before.
+// Every successful resolution adds another one to the persisted model.
+var SEED_ANCHORS = []Anchor{
+	{VideoId: 47198535725, Time: time.Unix(1664038929, 0).UTC()},
+	{VideoId: 47238989357, Time: time.Date(2022, time.October, 2, 1, 31, 0, 0, time.UTC)},
+}
+
+const (
+	minWindowSlack    = 10 * time.Minute
+	relativeSlackRate = 0.1
+)
+
+var (
+	ErrNotEnoughAnchors = errors.New("at least 2 anchors with different video ids are needed")
+	ErrNonMonotonic     = errors.New("anchors do not increase with time")
+)
+
+// An Anchor is a known mapping from a video id to the start time of its stream.
+type Anchor struct {
+	VideoId int64     `json:"videoid"`
+	Time    time.Time `json:"time"`
+}
+
+// A TimeWindow is the range of start times that a video id plausibly has.
+type TimeWindow struct {
+	Start time.Time
+	Guess time.Time
+	End   time.Time
+}
+
+// An AnchorModel estimates start times from video ids, which grow roughly monotonically with time.
+type AnchorModel struct {
+	Anchors []Anchor `json:"anchors"` // sorted by VideoId
+}
+
+func NewAnchorModel(anchors []Anchor) *AnchorModel {
+	model := &AnchorModel{}
+	for _, anchor := range anchors {
+		model.addAnchor(anchor)
+	}
+	return model
+}
+
+// LoadAnchorModel reads the anchors at path and merges them with the seed anchors.
+// A missing file is not an error.
+func LoadAnchorModel(path string) (*AnchorModel, error) {
+	model := NewAnchorModel(SEED_ANCHORS)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return model, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stored := AnchorModel{}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	for _, anchor := range stored.Anchors {
+		model.addAnchor(anchor)
+	}
+	return model, nil
+}
+
+func (model *AnchorModel) Save(path string) error {
+	return writeJsonFile(path, model)
+}
+
+// Add records the start time of a resolved video.
+func (model *AnchorModel) Add(videoData *VideoData) error {
+	videoId, err := strconv.ParseInt(videoData.VideoId, 10, 64)
+	if err != nil {
+		return err
+	}
+	model.addAnchor(Anchor{VideoId: videoId, Time: videoData.Time.UTC()})
+	return nil
+}
+
+func (model *AnchorModel) addAnchor(anchor Anchor) {
+	index := sort.Search(len(model.Anchors), func(i int) bool {
+		return model.Anchors[i].VideoId >= anchor.VideoId
+	})
+	if index < len(model.Anchors) && model.Anchors[index].VideoId == anchor.VideoId {
+		model.Anchors[index] = anchor
+		return
+	}
+	model.Anchors = append(model.Anchors, Anchor{})
+	copy(model.Anchors[index+1:], model.Anchors[index:])
+	model.Anchors[index] = anchor
+}
+
+// Estimate interpolates between the neighbouring anchors of videoId.
+// Outside of the anchors, it extrapolates with the rate of the two nearest anchors.
+// The window grows with the distance to the nearest anchor.
+func (model *AnchorModel) Estimate(videoId string) (*TimeWindow, error) {
+	id, err := strconv.ParseInt(videoId, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	numAnchors := len(model.Anchors)
+	if numAnchors < 2 {
+		return nil, ErrNotEnoughAnchors
+	}
+	index := sort.Search(numAnchors, func(i int) bool {
+		return model.Anchors[i].VideoId >= id
+	})
+	if index < numAnchors && model.Anchors[index].VideoId == id {
+		return newTimeWindow(model.Anchors[index].Time, 0), nil
+	}
+	var lo, hi Anchor
+	switch {
+	case index == 0:
+		lo, hi = model.Anchors[0], model.Anchors[1]
+	case index == numAnchors:
+		lo, hi = model.Anchors[numAnchors-2], model.Anchors[numAnchors-1]
+	default:
+		lo, hi = model.Anchors[index-1], model.Anchors[index]
+	}
+	if !hi.Time.After(lo.Time) {
+		return nil, ErrNonMonotonic
+	}
+	rate := float64(hi.Time.Sub(lo.Time)) / float64(hi.VideoId-lo.VideoId)
+	guess := lo.Time.Add(time.Duration(rate * float64(id-lo.VideoId)))
+	distance := guess.Sub(lo.Time)
+	if hiDistance := hi.Time.Sub(guess); absDuration(hiDistance) < absDuration(distance) {
+		distance = hiDistance
+	}
+	return newTimeWindow(guess, absDuration(distance)), nil
+}
+
+func newTimeWindow(guess time.Time, distance time.Duration) *TimeWindow {
+	slack := minWindowSlack + time.Duration(math.Round(relativeSlackRate*float64(distance)))
+	return &TimeWindow{
+		Start: guess.Add(-slack).Truncate(time.Second),
+		Guess: guess.Truncate(time.Second),
+		End:   guess.Add(slack).Truncate(time.Second),
+	}
+}
+
+func (window *TimeWindow) Seconds() int {
+	return int(window.End.Sub(window.Start) / time.Second)
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func writeJsonFile(path string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,41 @@
+package vods_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func TestAnchorModelEstimate(t *testing.T) {
+	start := time.Date(2022, time.October, 1, 0, 0, 0, 0, time.UTC)
+	model := vods.NewAnchorModel([]vods.Anchor{
+		{VideoId: 2000, Time: start.Add(2000 * time.Hour)},
+		{VideoId: 1000, Time: start.Add(1000 * time.Hour)},
+	})
+	window, err := model.Estimate("1500")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, window.Guess, start.Add(1500*time.Hour))
+	if !window.Start.Before(window.Guess) || !window.End.After(window.Guess) {
+		t.Fatalf("window %v does not contain its guess", window)
+	}
+	extrapolated, err := model.Estimate("2100")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, extrapolated.Guess, start.Add(2100*time.Hour))
+	if extrapolated.Seconds() >= window.Seconds() {
+		t.Fatalf("window near an anchor should be narrower than a window between distant anchors")
+	}
+}
+
+func TestAnchorModelNeedsTwoAnchors(t *testing.T) {
+	model := vods.NewAnchorModel([]vods.Anchor{{VideoId: 1000, Time: time.Unix(0, 0)}})
+	_, err := model.Estimate("1500")
+	if !errors.Is(err, vods.ErrNotEnoughAnchors) {
+		t.Fatalf(`got %v want %v`, err, vods.ErrNotEnoughAnchors)
+	}
+}
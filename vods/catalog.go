@@ -0,0 +1,134 @@
+package vods
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	CatalogAlive    = "alive"
+	CatalogVanished = "vanished"
+)
+
+// A CatalogEntry is a vod that was resolved.
+type CatalogEntry struct {
+	Streamer        string     `json:"streamer"`
+	VideoId         string     `json:"videoId"`
+	StartTime       time.Time  `json:"startTime"`
+	DurationSeconds float64    `json:"durationSeconds"`
+	Domain          string     `json:"domain"`
+	Url             string     `json:"url"`
+	PathScheme      string     `json:"pathScheme"`
+	Status          string     `json:"status"` // CatalogAlive or CatalogVanished
+	File            string     `json:"file,omitempty"`
+	SidecarFile     string     `json:"sidecarFile,omitempty"`
+	ResolvedAt      *time.Time `json:"resolvedAt,omitempty"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+// A Catalog is an index of the resolved vods by video id, stored as a JSON file.
+type Catalog struct {
+	Entries map[string]*CatalogEntry `json:"entries"`
+}
+
+func LoadCatalog(path string) (*Catalog, error) {
+	catalog := &Catalog{Entries: map[string]*CatalogEntry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return catalog, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, catalog); err != nil {
+		return nil, err
+	}
+	if catalog.Entries == nil {
+		catalog.Entries = map[string]*CatalogEntry{}
+	}
+	return catalog, nil
+}
+
+func (catalog *Catalog) Save(path string) error {
+	return writeJsonFile(path, catalog)
+}
+
+// Add adds or updates the entry of the vod of metadata. The fields that metadata doesn't have are kept.
+func (catalog *Catalog) Add(metadata *PlaylistMetadata, file string, status string, now time.Time) {
+	entry, ok := catalog.Entries[metadata.VideoId]
+	if !ok {
+		entry = &CatalogEntry{VideoId: metadata.VideoId}
+		catalog.Entries[metadata.VideoId] = entry
+	}
+	entry.Streamer = metadata.Streamer
+	entry.Status = status
+	entry.UpdatedAt = now
+	if metadata.MatchedTime != nil {
+		entry.StartTime = *metadata.MatchedTime
+	}
+	if metadata.DurationSeconds > 0 {
+		entry.DurationSeconds = metadata.DurationSeconds
+	}
+	if metadata.Url != "" {
+		entry.Domain = metadata.Domain
+		entry.Url = metadata.Url
+		entry.PathScheme = metadata.PathScheme
+	}
+	if metadata.ResolvedAt != nil {
+		entry.ResolvedAt = metadata.ResolvedAt
+	}
+	if file != "" {
+		entry.File = file
+		entry.SidecarFile = SidecarPath(file)
+	}
+}
+
+// A CatalogQuery selects catalog entries. Zero value fields match everything.
+type CatalogQuery struct {
+	Streamer    string // not case sensitive
+	Since       time.Time
+	Until       time.Time
+	MinDuration time.Duration
+	MaxDuration time.Duration
+	Status      string
+}
+
+func (query *CatalogQuery) matches(entry *CatalogEntry) bool {
+	duration := time.Duration(entry.DurationSeconds * float64(time.Second))
+	switch {
+	case query.Streamer != "" && !strings.EqualFold(query.Streamer, entry.Streamer):
+		return false
+	case !query.Since.IsZero() && entry.StartTime.Before(query.Since):
+		return false
+	case !query.Until.IsZero() && !entry.StartTime.Before(query.Until):
+		return false
+	case query.MinDuration > 0 && duration < query.MinDuration:
+		return false
+	case query.MaxDuration > 0 && duration > query.MaxDuration:
+		return false
+	case query.Status != "" && query.Status != entry.Status:
+		return false
+	}
+	return true
+}
+
+// Search returns the entries that match query, the most recent first.
+func (catalog *Catalog) Search(query CatalogQuery) []CatalogEntry {
+	entries := []CatalogEntry{}
+	for _, entry := range catalog.Entries {
+		if query.matches(entry) {
+			entries = append(entries, *entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].StartTime.Equal(entries[j].StartTime) {
+			return entries[i].VideoId < entries[j].VideoId
+		}
+		return entries[i].StartTime.After(entries[j].StartTime)
+	})
+	return entries
+}
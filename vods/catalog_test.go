@@ -0,0 +1,64 @@
+package vods_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func catalogMetadata(streamer string, videoId string, start time.Time, duration time.Duration) *vods.PlaylistMetadata {
+	metadata := trackedMetadata(streamer, videoId, start)
+	metadata.DurationSeconds = duration.Seconds()
+	metadata.Url = "https://example.com/" + videoId + "/chunked/index-dvr.m3u8"
+	return metadata
+}
+
+func TestCatalogSearch(t *testing.T) {
+	now := time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	catalog, err := vods.LoadCatalog(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	catalog.Add(catalogMetadata("streamer", "1", now.AddDate(0, 0, -100), 3*time.Hour), "old.m3u8", vods.CatalogAlive, now)
+	catalog.Add(catalogMetadata("streamer", "2", now.AddDate(0, 0, -10), 3*time.Hour), "long.m3u8", vods.CatalogAlive, now)
+	catalog.Add(catalogMetadata("Streamer", "3", now.AddDate(0, 0, -5), time.Hour), "short.m3u8", vods.CatalogAlive, now)
+	catalog.Add(catalogMetadata("other", "4", now.AddDate(0, 0, -1), 4*time.Hour), "other.m3u8", vods.CatalogAlive, now)
+	if err := catalog.Save(path); err != nil {
+		t.Fatalf(err.Error())
+	}
+	catalog, err = vods.LoadCatalog(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(catalog.Search(vods.CatalogQuery{})), 4)
+	entries := catalog.Search(vods.CatalogQuery{Streamer: "STREAMER", Since: now.AddDate(0, 0, -30)})
+	assertEqual(t, len(entries), 2)
+	assertEqual(t, entries[0].VideoId, "3")
+	assertEqual(t, entries[1].VideoId, "2")
+	entries = catalog.Search(vods.CatalogQuery{Streamer: "streamer", MinDuration: 2 * time.Hour})
+	assertEqual(t, len(entries), 2)
+	assertEqual(t, entries[0].VideoId, "2")
+	assertEqual(t, entries[0].File, "long.m3u8")
+	assertEqual(t, entries[0].SidecarFile, "long.json")
+	assertEqual(t, entries[1].VideoId, "1")
+}
+
+func TestCatalogAddKeepsFields(t *testing.T) {
+	now := time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC)
+	start := now.AddDate(0, 0, -3)
+	catalog, err := vods.LoadCatalog(filepath.Join(t.TempDir(), "catalog.json"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	catalog.Add(catalogMetadata("streamer", "1", start, 2*time.Hour), "vod.m3u8", vods.CatalogAlive, now)
+	catalog.Add(trackedMetadata("streamer", "1", start), "", vods.CatalogVanished, now.Add(time.Hour))
+	entries := catalog.Search(vods.CatalogQuery{Status: vods.CatalogVanished})
+	assertEqual(t, len(entries), 1)
+	assertEqual(t, entries[0].File, "vod.m3u8")
+	assertEqual(t, entries[0].DurationSeconds, 7200.0)
+	assertEqual(t, entries[0].Url, "https://example.com/1/chunked/index-dvr.m3u8")
+	assertEqual(t, entries[0].UpdatedAt.Equal(now.Add(time.Hour)), true)
+}
@@ -0,0 +1,142 @@
+package vods
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A TrackedVod is a vod that was found, and when it was last known to exist.
+type TrackedVod struct {
+	Streamer      string     `json:"streamer"`
+	VideoId       string     `json:"videoId"`
+	StartTime     time.Time  `json:"startTime"`
+	Url           string     `json:"url,omitempty"`
+	File          string     `json:"file,omitempty"`
+	LastSeenAlive time.Time  `json:"lastSeenAlive"`
+	VanishedAt    *time.Time `json:"vanishedAt,omitempty"`
+}
+
+// An ExpiryTracker remembers the vods that were found, by video id.
+type ExpiryTracker struct {
+	Vods map[string]*TrackedVod `json:"vods"`
+}
+
+func LoadExpiryTracker(path string) (*ExpiryTracker, error) {
+	tracker := &ExpiryTracker{Vods: map[string]*TrackedVod{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tracker, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, tracker); err != nil {
+		return nil, err
+	}
+	if tracker.Vods == nil {
+		tracker.Vods = map[string]*TrackedVod{}
+	}
+	return tracker, nil
+}
+
+func (tracker *ExpiryTracker) Save(path string) error {
+	return writeJsonFile(path, tracker)
+}
+
+// SeenAlive records that the vod of metadata existed at when.
+func (tracker *ExpiryTracker) SeenAlive(metadata *PlaylistMetadata, file string, when time.Time) {
+	vod := tracker.vod(metadata)
+	vod.Url = metadata.Url
+	if file != "" {
+		vod.File = file
+	}
+	if when.After(vod.LastSeenAlive) {
+		vod.LastSeenAlive = when
+	}
+	vod.VanishedAt = nil
+}
+
+// SeenVanished records that the vod of metadata was gone at when.
+func (tracker *ExpiryTracker) SeenVanished(metadata *PlaylistMetadata, when time.Time) {
+	vod := tracker.vod(metadata)
+	if vod.VanishedAt == nil {
+		vod.VanishedAt = &when
+	}
+}
+
+func (tracker *ExpiryTracker) vod(metadata *PlaylistMetadata) *TrackedVod {
+	vod, ok := tracker.Vods[metadata.VideoId]
+	if !ok {
+		vod = &TrackedVod{Streamer: metadata.Streamer, VideoId: metadata.VideoId}
+		tracker.Vods[metadata.VideoId] = vod
+	}
+	if metadata.MatchedTime != nil {
+		vod.StartTime = *metadata.MatchedTime
+	}
+	return vod
+}
+
+// RetentionRules are how many days vods are kept after they start.
+type RetentionRules struct {
+	DefaultDays int            `json:"defaultDays"`
+	Streamers   map[string]int `json:"streamers"` // days by streamer name
+}
+
+// DefaultRetentionRules keep vods for 14 days. Twitch keeps the vods of partners, Turbo and Prime users for 60 days.
+var DefaultRetentionRules = RetentionRules{DefaultDays: 14}
+
+// LoadRetentionRules returns the default rules if there is no file at path.
+func LoadRetentionRules(path string) (*RetentionRules, error) {
+	rules := DefaultRetentionRules
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &rules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// Retention is how long the vods of streamer are kept. Streamer names are not case sensitive.
+func (rules *RetentionRules) Retention(streamer string) time.Duration {
+	days := rules.DefaultDays
+	for name, streamerDays := range rules.Streamers {
+		if strings.EqualFold(name, streamer) {
+			days = streamerDays
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// An ExpiringVod is a tracked vod with when it is estimated to be deleted.
+type ExpiringVod struct {
+	TrackedVod
+	EstimatedDeletion time.Time `json:"estimatedDeletion"`
+}
+
+// Expiring returns the vods that haven't vanished and are estimated to be deleted before now + within,
+// sorted by the estimated deletion. Vods that are past their estimated deletion are included.
+func (tracker *ExpiryTracker) Expiring(rules *RetentionRules, now time.Time, within time.Duration) []ExpiringVod {
+	expiring := []ExpiringVod{}
+	for _, vod := range tracker.Vods {
+		if vod.VanishedAt != nil {
+			continue
+		}
+		deletion := vod.StartTime.Add(rules.Retention(vod.Streamer))
+		if deletion.Before(now.Add(within)) {
+			expiring = append(expiring, ExpiringVod{TrackedVod: *vod, EstimatedDeletion: deletion})
+		}
+	}
+	sort.Slice(expiring, func(i, j int) bool {
+		return expiring[i].EstimatedDeletion.Before(expiring[j].EstimatedDeletion)
+	})
+	return expiring
+}
@@ -0,0 +1,43 @@
+package vods_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func trackedMetadata(streamer string, videoId string, start time.Time) *vods.PlaylistMetadata {
+	metadata := vods.NewPlaylistMetadata(&vods.VideoData{StreamerName: streamer, VideoId: videoId})
+	metadata.MatchedTime = &start
+	return metadata
+}
+
+func TestExpiring(t *testing.T) {
+	now := time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "expiry.json")
+	tracker, err := vods.LoadExpiryTracker(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	tracker.SeenAlive(trackedMetadata("partner", "1", now.AddDate(0, 0, -58)), "partner.m3u8", now)
+	tracker.SeenAlive(trackedMetadata("affiliate", "2", now.AddDate(0, 0, -13)), "affiliate.m3u8", now)
+	tracker.SeenAlive(trackedMetadata("affiliate", "3", now.AddDate(0, 0, -1)), "recent.m3u8", now)
+	tracker.SeenAlive(trackedMetadata("affiliate", "4", now.AddDate(0, 0, -20)), "gone.m3u8", now)
+	tracker.SeenVanished(trackedMetadata("affiliate", "4", now.AddDate(0, 0, -20)), now)
+	if err := tracker.Save(path); err != nil {
+		t.Fatalf(err.Error())
+	}
+	tracker, err = vods.LoadExpiryTracker(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	rules := &vods.RetentionRules{DefaultDays: 14, Streamers: map[string]int{"Partner": 60}}
+	expiring := tracker.Expiring(rules, now, 72*time.Hour)
+	assertEqual(t, len(expiring), 2)
+	assertEqual(t, expiring[0].VideoId, "2")
+	assertEqual(t, expiring[0].EstimatedDeletion.Equal(now.AddDate(0, 0, 1)), true)
+	assertEqual(t, expiring[1].VideoId, "1")
+	assertEqual(t, expiring[1].File, "partner.m3u8")
+}
@@ -0,0 +1,4 @@
+package vods
+
+// ResolveAddr exports resolveAddr to the tests of vods_test.
+var ResolveAddr = resolveAddr
@@ -0,0 +1,123 @@
+package vods
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Formats of the files of an input feed.
+const (
+	FeedGovods        = "govods"        // the JSON array read by the stdin command
+	FeedSullyGnome    = "sullygnome"    // a response of the SullyGnome streams table api
+	FeedTwitchTracker = "twitchtracker" // a JSON array like govods, with times in the TwitchTracker format
+)
+
+// A FeedEntry is a stream of a feed in the govods or twitchtracker format, and of the stdin command.
+type FeedEntry struct {
+	StartTime    string `json:"time"`
+	StreamID     string `json:"id"`
+	StreamerName string `json:"name"`
+}
+
+// VideoData returns the stream of the entry. Times are in RFC 3339 in the govods format.
+func (entry *FeedEntry) VideoData(format string) (VideoData, error) {
+	var videoData VideoData
+	var err error
+	if format == FeedTwitchTracker {
+		twitchData := TwitchTrackerData{StreamerName: entry.StreamerName, VideoId: entry.StreamID, UtcTime: entry.StartTime}
+		videoData, err = twitchData.GetVideoData()
+	} else {
+		videoData = VideoData{StreamerName: entry.StreamerName, VideoId: entry.StreamID}
+		videoData.Time, err = time.Parse(time.RFC3339, entry.StartTime)
+	}
+	if err != nil {
+		return VideoData{}, err
+	}
+	return videoData, checkFeedVideoData(videoData)
+}
+
+type sullyGnomeFeed struct {
+	Data []json.RawMessage `json:"data"`
+}
+
+type sullyGnomeFeedEntry struct {
+	StartDateTime string      `json:"startDateTime"`
+	StreamId      json.Number `json:"streamId"`
+	ChannelUrl    string      `json:"channelurl"`
+}
+
+// A FeedRowError is a row of a feed that was skipped because it isn't a valid stream.
+type FeedRowError struct {
+	Row int // index of the row in the feed, from 0
+	Err error
+}
+
+func (rowErr FeedRowError) Error() string {
+	return fmt.Sprint("row ", rowErr.Row, ": ", rowErr.Err)
+}
+
+func (rowErr FeedRowError) Unwrap() error {
+	return rowErr.Err
+}
+
+func checkFeedVideoData(videoData VideoData) error {
+	if videoData.StreamerName == "" || videoData.VideoId == "" {
+		return errors.New("the stream has no streamer name or video id")
+	}
+	return nil
+}
+
+// ParseFeed returns the streams of a file of an input feed in format.
+// Rows that aren't valid streams are skipped and returned as FeedRowErrors, so they don't hide the other streams.
+// It only fails if the file isn't a feed in format.
+func ParseFeed(format string, data []byte) ([]VideoData, []FeedRowError, error) {
+	videos := []VideoData{}
+	rowErrs := []FeedRowError{}
+	switch format {
+	case FeedGovods, FeedTwitchTracker, "":
+		rows := []json.RawMessage{}
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, nil, err
+		}
+		for i, row := range rows {
+			entry := FeedEntry{}
+			err := json.Unmarshal(row, &entry)
+			var videoData VideoData
+			if err == nil {
+				videoData, err = entry.VideoData(format)
+			}
+			if err != nil {
+				rowErrs = append(rowErrs, FeedRowError{Row: i, Err: err})
+				continue
+			}
+			videos = append(videos, videoData)
+		}
+	case FeedSullyGnome:
+		feed := sullyGnomeFeed{}
+		if err := json.Unmarshal(data, &feed); err != nil {
+			return nil, nil, err
+		}
+		for i, row := range feed.Data {
+			entry := sullyGnomeFeedEntry{}
+			err := json.Unmarshal(row, &entry)
+			var videoData VideoData
+			if err == nil {
+				sullygnomeData := SullyGnomeData{StreamerName: entry.ChannelUrl, VideoId: entry.StreamId.String(), UtcTime: entry.StartDateTime}
+				videoData, err = sullygnomeData.GetVideoData()
+			}
+			if err == nil {
+				err = checkFeedVideoData(videoData)
+			}
+			if err != nil {
+				rowErrs = append(rowErrs, FeedRowError{Row: i, Err: err})
+				continue
+			}
+			videos = append(videos, videoData)
+		}
+	default:
+		return nil, nil, errors.New(fmt.Sprint("feed format ", format, " is not ", FeedGovods, ", ", FeedSullyGnome, " or ", FeedTwitchTracker))
+	}
+	return videos, rowErrs, nil
+}
@@ -0,0 +1,86 @@
+package vods_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func TestParseFeed(t *testing.T) {
+	sullyGnome := `{"draw":1,"data":[{"startDateTime":"2024-03-26T20:49:54Z","streamId":43903162955,"channelurl":"streamer","length":120}]}`
+	videos, _, err := vods.ParseFeed(vods.FeedSullyGnome, []byte(sullyGnome))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(videos), 1)
+	assertEqual(t, videos[0], vods.VideoData{StreamerName: "streamer", VideoId: "43903162955", Time: time.Date(2024, 3, 26, 20, 49, 54, 0, time.UTC)})
+	govods := `[{"time":"2024-03-19T14:32:06Z","id":"42424695993","name":"streamer"}]`
+	videos, _, err = vods.ParseFeed(vods.FeedGovods, []byte(govods))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, videos[0].Time.Equal(time.Date(2024, 3, 19, 14, 32, 6, 0, time.UTC)), true)
+	twitchTracker := `[{"time":"2024-03-19 14:32:06","id":"42424695993","name":"streamer"}]`
+	videos, _, err = vods.ParseFeed(vods.FeedTwitchTracker, []byte(twitchTracker))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, videos[0].Time.Equal(time.Date(2024, 3, 19, 14, 32, 6, 0, time.UTC)), true)
+	videos, rowErrs, err := vods.ParseFeed(vods.FeedGovods, []byte(`[{"time":"2024-03-19T14:32:06Z","name":"streamer"}]`))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(videos), 0)
+	assertEqual(t, len(rowErrs), 1)
+	for _, data := range []string{`{"time":"2024-03-19T14:32:06Z"}`, `not json`} {
+		if _, _, err := vods.ParseFeed(vods.FeedGovods, []byte(data)); err == nil {
+			t.Fatalf("feed %v was accepted", data)
+		}
+	}
+	if _, _, err := vods.ParseFeed("csv", []byte(govods)); err == nil {
+		t.Fatalf("an unknown format was accepted")
+	}
+}
+
+func TestParseFeedSkipsInvalidRows(t *testing.T) {
+	for _, test := range []struct {
+		format string
+		data   string
+	}{
+		{vods.FeedGovods, `[
+			{"time":"2024-03-19T14:32:06Z","id":"42424695993","name":"streamer"},
+			{"time":"2024-03-19 14:32:06","id":"42424695994","name":"streamer"},
+			{"time":"2024-03-19T14:32:06Z","id":42424695995,"name":"streamer"},
+			{"time":"2024-03-19T14:32:06Z","name":"streamer"},
+			{"time":"2024-03-20T14:32:06+01:00","id":"42424695997","name":"streamer"}
+		]`},
+		{vods.FeedTwitchTracker, `[
+			{"time":"2024-03-19 14:32:06","id":"42424695993","name":"streamer"},
+			{"time":"2024-03-19T14:32:06Z","id":"42424695994","name":"streamer"},
+			"streamer",
+			{"time":"2024-03-19 14:32:06","id":"42424695996"},
+			{"time":"2024-03-20 13:32:06","id":"42424695997","name":"streamer"}
+		]`},
+		{vods.FeedSullyGnome, `{"data":[
+			{"startDateTime":"2024-03-19T14:32:06Z","streamId":42424695993,"channelurl":"streamer"},
+			{"startDateTime":"yesterday","streamId":42424695994,"channelurl":"streamer"},
+			{"startDateTime":"2024-03-19T14:32:06Z","streamId":true,"channelurl":"streamer"},
+			{"startDateTime":"2024-03-19T14:32:06Z","streamId":42424695996},
+			{"startDateTime":"2024-03-20T13:32:06Z","streamId":42424695997,"channelurl":"streamer"}
+		]}`},
+	} {
+		videos, rowErrs, err := vods.ParseFeed(test.format, []byte(test.data))
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		assertEqual(t, len(videos), 2)
+		assertEqual(t, videos[0].VideoId, "42424695993")
+		assertEqual(t, videos[1].VideoId, "42424695997")
+		assertEqual(t, videos[1].Time.Equal(time.Date(2024, 3, 20, 13, 32, 6, 0, time.UTC)), true)
+		assertEqual(t, len(rowErrs), 3)
+		for i, rowErr := range rowErrs {
+			assertEqual(t, rowErr.Row, i+1)
+		}
+	}
+}
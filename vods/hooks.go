@@ -0,0 +1,220 @@
+package vods
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Events that hooks fire on.
+const (
+	HookFound            = "found"             // a lookup wrote a playlist
+	HookNotFound         = "not-found"         // a lookup found no playlist, or a refreshed playlist vanished
+	HookFailed           = "failed"            // a lookup or refresh failed for another reason, such as rate limits
+	HookSegmentsMissing  = "segments-missing"  // a written playlist is missing segments that were checked
+	HookDownloadComplete = "download-complete" // the download command of the watch command succeeded
+)
+
+var HookEvents = []string{HookFound, HookNotFound, HookFailed, HookSegmentsMissing, HookDownloadComplete}
+
+// A HookEvent is what a hook receives, as JSON.
+type HookEvent struct {
+	Event    string      `json:"event"`
+	Time     time.Time   `json:"time"`
+	Streamer string      `json:"streamer,omitempty"`
+	VideoId  string      `json:"videoId,omitempty"`
+	File     string      `json:"file,omitempty"`
+	Url      string      `json:"url,omitempty"`
+	Result   interface{} `json:"result"` // the JSON record of the lookup, refresh or download
+}
+
+// A Hook posts events to Url, or runs Command with the event on stdin and in GOVODS_* environment variables.
+type Hook struct {
+	Name    string            `json:"name"`
+	Events  []string          `json:"events"` // every event if empty
+	Url     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Command []string          `json:"command"`
+}
+
+func (hook *Hook) String() string {
+	if hook.Name != "" {
+		return hook.Name
+	}
+	if hook.Url != "" {
+		return hook.Url
+	}
+	return strings.Join(hook.Command, " ")
+}
+
+func (hook *Hook) handles(event string) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, handled := range hook.Events {
+		if handled == event {
+			return true
+		}
+	}
+	return false
+}
+
+// hookConfig is the file of the hooks.
+type hookConfig struct {
+	Hooks        []*Hook `json:"hooks"`
+	MaxAttempts  int     `json:"maxAttempts"`  // attempts of a hook for an event, including the first one
+	RetryDelay   string  `json:"retryDelay"`   // delay after the first attempt, doubled after every attempt
+	Timeout      string  `json:"timeout"`      // of a single attempt
+	EventTimeout string  `json:"eventTimeout"` // of every attempt of every hook of an event
+}
+
+// Hooks fire the hooks of a hooks file.
+type Hooks struct {
+	Hooks        []*Hook
+	Retry        RetryPolicy
+	Timeout      time.Duration
+	EventTimeout time.Duration // no limit if 0
+	Client       *http.Client
+}
+
+// LoadHooks returns no hooks if there is no file at path.
+func LoadHooks(path string) (*Hooks, error) {
+	config := &hookConfig{MaxAttempts: 3, RetryDelay: "1s", Timeout: "10s", EventTimeout: "30s"}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = []byte("{}"), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, errors.New(fmt.Sprint("invalid hooks file ", path, ": ", err))
+	}
+	retry := DefaultRetryPolicy
+	retry.MaxAttempts = config.MaxAttempts
+	if retry.BaseDelay, err = time.ParseDuration(config.RetryDelay); err != nil {
+		return nil, errors.New(fmt.Sprint("invalid retryDelay in hooks file ", path, ": ", err))
+	}
+	retry.MaxDelay = time.Minute
+	timeout, err := time.ParseDuration(config.Timeout)
+	if err != nil {
+		return nil, errors.New(fmt.Sprint("invalid timeout in hooks file ", path, ": ", err))
+	}
+	eventTimeout, err := time.ParseDuration(config.EventTimeout)
+	if err != nil {
+		return nil, errors.New(fmt.Sprint("invalid eventTimeout in hooks file ", path, ": ", err))
+	}
+	for _, hook := range config.Hooks {
+		if (hook.Url == "") == (len(hook.Command) == 0) {
+			return nil, errors.New(fmt.Sprint("hook ", hook, " in ", path, " needs either a url or a command"))
+		}
+		for _, event := range hook.Events {
+			if !isHookEvent(event) {
+				return nil, errors.New(fmt.Sprint("hook ", hook, " in ", path, " has event ", event, " which is not one of ", strings.Join(HookEvents, ", ")))
+			}
+		}
+	}
+	return &Hooks{Hooks: config.Hooks, Retry: retry, Timeout: timeout, EventTimeout: eventTimeout, Client: &http.Client{}}, nil
+}
+
+func isHookEvent(event string) bool {
+	for _, known := range HookEvents {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Fire runs every hook of the event, retrying each one up to the attempts of the retry policy.
+// It returns the errors of the hooks that failed every attempt, or didn't finish within the EventTimeout of the event.
+func (hooks *Hooks) Fire(ctx context.Context, event *HookEvent) []error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return []error{err}
+	}
+	if hooks.EventTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hooks.EventTimeout)
+		defer cancel()
+	}
+	errs := []error{}
+	for _, hook := range hooks.Hooks {
+		if !hook.handles(event.Event) {
+			continue
+		}
+		if err := hooks.fireWithRetries(ctx, hook, event, payload); err != nil {
+			errs = append(errs, errors.New(fmt.Sprint("hook ", hook, " failed on ", event.Event, ": ", err)))
+		}
+	}
+	return errs
+}
+
+func (hooks *Hooks) fireWithRetries(ctx context.Context, hook *Hook, event *HookEvent, payload []byte) error {
+	for attempt := 1; ; attempt++ {
+		retryable, err := hooks.fireOnce(ctx, hook, event, payload)
+		if err == nil || !retryable || attempt >= hooks.Retry.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(hooks.Retry.Delay(attempt, nil)):
+		}
+	}
+}
+
+// fireOnce reports whether a failure is worth retrying. A command that can't be run is not.
+func (hooks *Hooks) fireOnce(ctx context.Context, hook *Hook, event *HookEvent, payload []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, hooks.Timeout)
+	defer cancel()
+	if hook.Url == "" {
+		command := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+		command.Stdin = bytes.NewReader(payload)
+		command.Env = append(os.Environ(),
+			"GOVODS_EVENT="+event.Event,
+			"GOVODS_STREAMER="+event.Streamer,
+			"GOVODS_VIDEO_ID="+event.VideoId,
+			"GOVODS_FILE="+event.File,
+			"GOVODS_URL="+event.Url,
+		)
+		output, err := command.CombinedOutput()
+		retryable := !errors.Is(err, exec.ErrNotFound) && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, os.ErrPermission)
+		if err != nil && len(output) > 0 {
+			return retryable, errors.New(fmt.Sprint(err, ": ", strings.TrimSpace(string(output))))
+		}
+		return retryable, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Url, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "govods")
+	for key, value := range hook.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := hooks.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return hooks.Retry.RetryableStatus[resp.StatusCode], &StatusError{StatusCode: resp.StatusCode}
+	}
+	return false, nil
+}
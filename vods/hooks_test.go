@@ -0,0 +1,145 @@
+package vods_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func writeHooks(t *testing.T, hooks string) *vods.Hooks {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hooks.json")
+	if err := os.WriteFile(path, []byte(hooks), 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+	loaded, err := vods.LoadHooks(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	return loaded
+}
+
+func TestHooksRetryWebhook(t *testing.T) {
+	attempts := 0
+	received := vods.HookEvent{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		body, _ := io.ReadAll(req.Body)
+		json.Unmarshal(body, &received)
+		assertEqual(t, req.Header.Get("Authorization"), "Bearer token")
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	hooks := writeHooks(t, `{"retryDelay": "1ms", "hooks": [
+		{"events": ["found"], "url": "`+server.URL+`", "headers": {"Authorization": "Bearer token"}}
+	]}`)
+	errs := hooks.Fire(context.Background(), &vods.HookEvent{Event: vods.HookNotFound})
+	assertEqual(t, len(errs), 0)
+	assertEqual(t, attempts, 0)
+	errs = hooks.Fire(context.Background(), &vods.HookEvent{Event: vods.HookFound, VideoId: "1", Result: map[string]int{"segments": 2}})
+	assertEqual(t, len(errs), 0)
+	assertEqual(t, attempts, 3)
+	assertEqual(t, received.Event, vods.HookFound)
+	assertEqual(t, received.VideoId, "1")
+	assertEqual(t, received.Time.IsZero(), false)
+}
+
+func TestHooksDontRetryClientErrors(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+	hooks := writeHooks(t, `{"retryDelay": "1ms", "hooks": [{"url": "`+server.URL+`"}]}`)
+	errs := hooks.Fire(context.Background(), &vods.HookEvent{Event: vods.HookFound})
+	assertEqual(t, len(errs), 1)
+	assertEqual(t, attempts, 1)
+}
+
+func TestHooksCommand(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	output := filepath.Join(t.TempDir(), "event.json")
+	hooks := writeHooks(t, `{"retryDelay": "1ms", "hooks": [
+		{"events": ["download-complete"], "command": ["/bin/sh", "-c", "cat > `+output+` && test \"$GOVODS_VIDEO_ID\" = 42"]}
+	]}`)
+	errs := hooks.Fire(context.Background(), &vods.HookEvent{Event: vods.HookDownloadComplete, VideoId: "42"})
+	assertEqual(t, len(errs), 0)
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	received := vods.HookEvent{}
+	if err := json.Unmarshal(data, &received); err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, received.Event, vods.HookDownloadComplete)
+	errs = hooks.Fire(context.Background(), &vods.HookEvent{Event: vods.HookDownloadComplete, VideoId: "7"})
+	assertEqual(t, len(errs), 1)
+}
+
+func TestLoadHooksValidates(t *testing.T) {
+	hooks, err := vods.LoadHooks(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(hooks.Hooks), 0)
+	for _, invalid := range []string{
+		`{"hooks": [{"events": ["found"]}]}`,
+		`{"hooks": [{"url": "http://localhost", "command": ["true"]}]}`,
+		`{"hooks": [{"url": "http://localhost", "events": ["lost"]}]}`,
+		`{"hooks": [], "retries": 3}`,
+		`{"hooks": [], "eventTimeout": "soon"}`,
+	} {
+		path := filepath.Join(t.TempDir(), "hooks.json")
+		os.WriteFile(path, []byte(invalid), 0644)
+		if _, err := vods.LoadHooks(path); err == nil {
+			t.Fatalf("invalid hooks %v were accepted", invalid)
+		}
+	}
+}
+
+func TestHooksDontRetryCommandsThatCantRun(t *testing.T) {
+	notExecutable := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+	for _, command := range []string{"govods-missing-hook", filepath.Join(t.TempDir(), "missing.sh"), notExecutable} {
+		// a retry would wait for the retry delay until the event times out
+		hooks := writeHooks(t, `{"retryDelay": "1h", "eventTimeout": "2s", "hooks": [{"command": ["`+command+`"]}]}`)
+		errs := hooks.Fire(context.Background(), &vods.HookEvent{Event: vods.HookFound})
+		assertEqual(t, len(errs), 1)
+		if strings.Contains(errs[0].Error(), "deadline") {
+			t.Fatalf("%v was retried: %v", command, errs[0])
+		}
+	}
+}
+
+func TestHooksEventTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// the server notices the request was canceled once the body is read
+		io.ReadAll(req.Body)
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+	hooks := writeHooks(t, `{"timeout": "1m", "eventTimeout": "50ms", "hooks": [{"url": "`+server.URL+`"}, {"url": "`+server.URL+`"}]}`)
+	start := time.Now()
+	errs := hooks.Fire(context.Background(), &vods.HookEvent{Event: vods.HookFound})
+	assertEqual(t, len(errs), 2)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("the hooks of an event took %v", elapsed)
+	}
+}
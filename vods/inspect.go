@@ -0,0 +1,90 @@
+package vods
+
+import (
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/grafov/m3u8"
+)
+
+// A DomainCount is how many segments of a playlist are on a domain.
+type DomainCount struct {
+	Domain   string `json:"domain"`
+	Segments int    `json:"segments"`
+}
+
+// A PlaylistSummary describes a decoded media playlist.
+type PlaylistSummary struct {
+	DurationSeconds float64        `json:"durationSeconds"`
+	Segments        int            `json:"segments"`
+	Streamer        string         `json:"streamer,omitempty"` // from the first segment url that has the video data
+	VideoId         string         `json:"videoId,omitempty"`
+	StartTime       *time.Time     `json:"startTime,omitempty"`
+	UrlPath         string         `json:"urlPath,omitempty"`
+	MutedRanges     []SegmentRange `json:"mutedRanges"`
+	NumberGaps      []SegmentRange `json:"numberGaps"` // segment numbers that are skipped, not indices
+	Domains         []DomainCount  `json:"domains"`
+	TwitchTotalSecs *float64       `json:"twitchTotalSecs,omitempty"`
+}
+
+// SameSegments reports whether two playlists have the same segment urls and durations.
+func SameSegments(a *m3u8.MediaPlaylist, b *m3u8.MediaPlaylist) bool {
+	if len(a.Segments) != len(b.Segments) {
+		return false
+	}
+	for i, segment := range a.Segments {
+		if segment.URI != b.Segments[i].URI || segment.Duration != b.Segments[i].Duration {
+			return false
+		}
+	}
+	return true
+}
+
+// SummarizeMediaPlaylist describes a playlist. The video data and domains are only known if the segment urls are explicit.
+func SummarizeMediaPlaylist(mediapl *m3u8.MediaPlaylist) *PlaylistSummary {
+	summary := &PlaylistSummary{
+		DurationSeconds: GetMediaPlaylistDuration(mediapl).Seconds(),
+		Segments:        len(mediapl.Segments),
+		MutedRanges:     []SegmentRange{},
+		NumberGaps:      []SegmentRange{},
+		Domains:         []DomainCount{},
+	}
+	muted := []bool{}
+	domainCounts := map[string]int{}
+	previousNumber := -1
+	for _, segment := range mediapl.Segments {
+		muted = append(muted, isMutedUri(segment.URI))
+		if number, ok := segmentNumber(segment.URI); ok {
+			if previousNumber >= 0 && number > previousNumber+1 {
+				summary.NumberGaps = append(summary.NumberGaps, SegmentRange{Start: previousNumber + 1, End: number})
+			}
+			previousNumber = number
+		}
+		u, err := url.Parse(segment.URI)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		domainCounts[u.Scheme+"://"+u.Host+"/"]++
+		if summary.UrlPath == "" {
+			if dwp, err := UrlToDomainWithPath(segment.URI); err == nil {
+				videoData := dwp.GetVideoData()
+				summary.Streamer = videoData.StreamerName
+				summary.VideoId = videoData.VideoId
+				summary.StartTime = &videoData.Time
+				summary.UrlPath = dwp.Path.UrlPath
+			}
+		}
+	}
+	summary.MutedRanges = toRanges(muted)
+	for domain, count := range domainCounts {
+		summary.Domains = append(summary.Domains, DomainCount{Domain: domain, Segments: count})
+	}
+	sort.Slice(summary.Domains, func(i, j int) bool {
+		return summary.Domains[i].Domain < summary.Domains[j].Domain
+	})
+	if totalSecs, ok := GetTwitchSeconds(mediapl, TagTwitchTotalSecs); ok {
+		summary.TwitchTotalSecs = &totalSecs
+	}
+	return summary
+}
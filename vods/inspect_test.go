@@ -0,0 +1,48 @@
+package vods_test
+
+import (
+	"testing"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func TestSummarizeMediaPlaylist(t *testing.T) {
+	mediapl, err := vods.DecodeMediaPlaylistFilterNilSegments([]byte(twitchPlaylist), true)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	dwp, err := vods.UrlToDomainWithPath("https://d1m7jfoe9zdc1j.cloudfront.net/c5992ececce7bd7d350d_gmhikaru_47198535725_1664038929/chunked/index-dvr.m3u8")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	vods.MuteMediaSegments(mediapl)
+	dwp.MakePathsExplicit(mediapl)
+	mediapl.Segments[1].URI = dwp.Domain + dwp.Path.UrlPath + "/chunked/5-muted.ts"
+	summary := vods.SummarizeMediaPlaylist(mediapl)
+	assertEqual(t, summary.DurationSeconds, 20.5)
+	assertEqual(t, summary.Segments, 2)
+	assertEqual(t, summary.Streamer, "gmhikaru")
+	assertEqual(t, summary.VideoId, "47198535725")
+	assertEqual(t, summary.StartTime.Unix(), int64(1664038929))
+	assertEqual(t, len(summary.MutedRanges), 1)
+	assertEqual(t, summary.MutedRanges[0], vods.SegmentRange{Start: 1, End: 2})
+	assertEqual(t, len(summary.NumberGaps), 1)
+	assertEqual(t, summary.NumberGaps[0], vods.SegmentRange{Start: 1, End: 5})
+	assertEqual(t, len(summary.Domains), 1)
+	assertEqual(t, summary.Domains[0], vods.DomainCount{Domain: "https://d1m7jfoe9zdc1j.cloudfront.net/", Segments: 2})
+	assertEqual(t, *summary.TwitchTotalSecs, 20.5)
+}
+
+func TestSameSegments(t *testing.T) {
+	a, err := vods.DecodeMediaPlaylistFilterNilSegments([]byte(twitchPlaylist), true)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	b, err := vods.DecodeMediaPlaylistFilterNilSegments([]byte(twitchPlaylist), true)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, vods.SameSegments(a, b), true)
+	vods.MuteMediaSegments(b)
+	assertEqual(t, vods.SameSegments(a, b), false)
+}
@@ -0,0 +1,158 @@
+package vods
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A Limiter bounds how many segment checks are in flight.
+type Limiter interface {
+	// Acquire blocks until another request may start.
+	Acquire(ctx context.Context) (LimiterToken, error)
+	// Release reports that the request of the token finished with err.
+	Release(token LimiterToken, err error)
+	// Limit is the current number of requests allowed in flight.
+	Limit() int
+}
+
+// A LimiterToken is handed out by Acquire and given back to Release.
+type LimiterToken struct {
+	start time.Time
+	epoch int
+}
+
+type fixedLimiter struct {
+	slots chan struct{}
+}
+
+// NewFixedLimiter allows concurrent requests in flight. concurrent must be positive.
+func NewFixedLimiter(concurrent int) Limiter {
+	return &fixedLimiter{slots: make(chan struct{}, concurrent)}
+}
+
+func (limiter *fixedLimiter) Acquire(ctx context.Context) (LimiterToken, error) {
+	select {
+	case <-ctx.Done():
+		return LimiterToken{}, ctx.Err()
+	case limiter.slots <- struct{}{}:
+		return LimiterToken{start: time.Now()}, nil
+	}
+}
+
+func (limiter *fixedLimiter) Release(token LimiterToken, err error) {
+	<-limiter.slots
+}
+
+func (limiter *fixedLimiter) Limit() int {
+	return cap(limiter.slots)
+}
+
+// AdaptiveOptions configures an AdaptiveLimiter.
+type AdaptiveOptions struct {
+	Initial           int
+	Min               int
+	Max               int
+	LatencyTolerance  float64 // the limit only grows while latency is within this factor of the fastest latency seen
+	DecreaseFactor    float64 // the limit is multiplied by this on timeouts, rate limits and connection errors
+	IncreasePerWindow float64 // the limit grows by this after a full window of healthy requests
+}
+
+var DefaultAdaptiveOptions = AdaptiveOptions{
+	Initial:           4,
+	Min:               1,
+	Max:               256,
+	LatencyTolerance:  2,
+	DecreaseFactor:    0.5,
+	IncreasePerWindow: 1,
+}
+
+// An AdaptiveLimiter finds a good concurrency with additive increase and multiplicative decrease (AIMD).
+// Each healthy request grows the limit by IncreasePerWindow / limit, so the limit grows by IncreasePerWindow
+// once per window of requests. Timeouts, rate limits and connection resets shrink it by DecreaseFactor,
+// at most once for the requests that were in flight at the same time.
+type AdaptiveLimiter struct {
+	options    AdaptiveOptions
+	mu         sync.Mutex
+	limit      float64
+	inFlight   int
+	epoch      int
+	minLatency time.Duration
+	released   chan struct{}
+}
+
+func NewAdaptiveLimiter(options AdaptiveOptions) *AdaptiveLimiter {
+	if options.Min < 1 {
+		options.Min = 1
+	}
+	if options.Max < options.Min {
+		options.Max = options.Min
+	}
+	initial := options.Initial
+	if initial < options.Min {
+		initial = options.Min
+	}
+	if initial > options.Max {
+		initial = options.Max
+	}
+	return &AdaptiveLimiter{
+		options:  options,
+		limit:    float64(initial),
+		released: make(chan struct{}),
+	}
+}
+
+func (limiter *AdaptiveLimiter) Acquire(ctx context.Context) (LimiterToken, error) {
+	for {
+		limiter.mu.Lock()
+		if limiter.inFlight < int(limiter.limit) {
+			limiter.inFlight++
+			token := LimiterToken{start: time.Now(), epoch: limiter.epoch}
+			limiter.mu.Unlock()
+			return token, nil
+		}
+		released := limiter.released
+		limiter.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return LimiterToken{}, ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+func (limiter *AdaptiveLimiter) Release(token LimiterToken, err error) {
+	latency := time.Since(token.start)
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.inFlight--
+	switch {
+	case IsThrottled(err):
+		// requests that started before the last decrease saw the old limit, so they don't count again
+		if token.epoch == limiter.epoch {
+			limiter.limit *= limiter.options.DecreaseFactor
+			if limiter.limit < float64(limiter.options.Min) {
+				limiter.limit = float64(limiter.options.Min)
+			}
+			limiter.epoch++
+		}
+	case err == nil || IsWrongCandidate(err):
+		if limiter.minLatency == 0 || latency < limiter.minLatency {
+			limiter.minLatency = latency
+		}
+		if float64(latency) <= limiter.options.LatencyTolerance*float64(limiter.minLatency) {
+			limiter.limit += limiter.options.IncreasePerWindow / limiter.limit
+			if limiter.limit > float64(limiter.options.Max) {
+				limiter.limit = float64(limiter.options.Max)
+			}
+		}
+	}
+	close(limiter.released)
+	limiter.released = make(chan struct{})
+}
+
+func (limiter *AdaptiveLimiter) Limit() int {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return int(limiter.limit)
+}
@@ -0,0 +1,39 @@
+package vods_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func TestAdaptiveLimiterAIMD(t *testing.T) {
+	options := vods.DefaultAdaptiveOptions
+	options.LatencyTolerance = 1e9
+	limiter := vods.NewAdaptiveLimiter(options)
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		token, err := limiter.Acquire(ctx)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		limiter.Release(token, nil)
+	}
+	grown := limiter.Limit()
+	if grown <= options.Initial {
+		t.Fatalf("limit %v did not grow from %v", grown, options.Initial)
+	}
+	tokens := []vods.LimiterToken{}
+	for i := 0; i < grown; i++ {
+		token, err := limiter.Acquire(ctx)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		tokens = append(tokens, token)
+	}
+	// every request in flight is rate limited, but the limit only halves once
+	for _, token := range tokens {
+		limiter.Release(token, &vods.StatusError{StatusCode: 429})
+	}
+	assertEqual(t, limiter.Limit(), grown/2)
+}
@@ -0,0 +1,207 @@
+package vods
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"   // players may refuse the playlist
+	LintWarning LintSeverity = "warning" // the playlist plays but is probably not what was intended
+	LintInfo    LintSeverity = "info"    // worth knowing, such as muted segments
+)
+
+// A LintIssue is a problem found by LintMediaPlaylist. Line is 1-based, or 0 for the whole playlist.
+type LintIssue struct {
+	Line     int          `json:"line"`
+	Severity LintSeverity `json:"severity"`
+	Message  string       `json:"message"`
+}
+
+func (issue LintIssue) String() string {
+	if issue.Line == 0 {
+		return fmt.Sprint(issue.Severity, ": ", issue.Message)
+	}
+	return fmt.Sprint("line ", issue.Line, ": ", issue.Severity, ": ", issue.Message)
+}
+
+// LintMediaPlaylist checks a media playlist for violations of the HLS spec and for things that
+// are unusual in Twitch index-dvr playlists. The issues are sorted by line.
+func LintMediaPlaylist(data []byte) []LintIssue {
+	linter := &playlistLinter{targetDuration: -1, previousNumber: -1, extensions: map[string]int{}}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		linter.lintLine(i+1, strings.TrimSpace(line))
+	}
+	linter.finish()
+	if _, err := DecodeMediaPlaylistFilterNilSegments(data, true); err != nil {
+		linter.add(0, LintError, fmt.Sprint("strict decoding failed: ", err))
+	}
+	sort.SliceStable(linter.issues, func(i, j int) bool {
+		return linter.issues[i].Line < linter.issues[j].Line
+	})
+	return linter.issues
+}
+
+type playlistLinter struct {
+	issues          []LintIssue
+	targetDuration  int
+	extinfLine      int // line of the EXTINF waiting for its uri, 0 if none
+	extinfDuration  float64
+	endlistLine     int
+	mapLine         int
+	numSegments     int
+	totalDuration   float64
+	extensions      map[string]int // extension of segment uris to the first line with it
+	previousNumber  int
+	numMuted        int
+	twitchTotalSecs float64
+	twitchTotalLine int
+}
+
+func (linter *playlistLinter) add(line int, severity LintSeverity, message string) {
+	linter.issues = append(linter.issues, LintIssue{Line: line, Severity: severity, Message: message})
+}
+
+func (linter *playlistLinter) lintLine(lineNumber int, line string) {
+	if lineNumber == 1 {
+		if line != "#EXTM3U" {
+			linter.add(lineNumber, LintError, "playlist does not start with #EXTM3U")
+		}
+		return
+	}
+	switch {
+	case line == "":
+	case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
+		if linter.targetDuration >= 0 {
+			linter.add(lineNumber, LintError, "#EXT-X-TARGETDURATION appears more than once")
+		}
+		targetDuration, err := strconv.Atoi(strings.TrimPrefix(line, "#EXT-X-TARGETDURATION:"))
+		if err != nil || targetDuration < 0 {
+			linter.add(lineNumber, LintError, "#EXT-X-TARGETDURATION is not a non-negative integer")
+			return
+		}
+		linter.targetDuration = targetDuration
+	case strings.HasPrefix(line, "#EXTINF:"):
+		if linter.extinfLine > 0 {
+			linter.add(linter.extinfLine, LintError, "#EXTINF is not followed by a segment uri")
+		}
+		value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+		duration, err := strconv.ParseFloat(value, 64)
+		if err != nil || duration < 0 {
+			linter.add(lineNumber, LintError, fmt.Sprint("#EXTINF duration ", value, " is not a non-negative number"))
+		}
+		linter.extinfLine = lineNumber
+		linter.extinfDuration = duration
+	case strings.HasPrefix(line, "#EXT-X-MAP:"):
+		linter.mapLine = lineNumber
+		if !strings.Contains(line, `URI="`) || strings.Contains(line, `URI=""`) {
+			linter.add(lineNumber, LintError, "#EXT-X-MAP has no URI")
+		}
+	case line == "#EXT-X-ENDLIST":
+		linter.endlistLine = lineNumber
+	case strings.HasPrefix(line, "#"):
+		for _, decoder := range TwitchTagDecoders() {
+			if !strings.HasPrefix(line, decoder.TagName()) {
+				continue
+			}
+			tag, err := decoder.Decode(line)
+			if err != nil {
+				linter.add(lineNumber, LintError, err.Error())
+			} else if tag.TagName() == TagTwitchTotalSecs {
+				linter.twitchTotalSecs = tag.(*TwitchSecondsTag).Seconds
+				linter.twitchTotalLine = lineNumber
+			}
+		}
+	default:
+		linter.lintSegment(lineNumber, line)
+	}
+}
+
+func (linter *playlistLinter) lintSegment(lineNumber int, uri string) {
+	if linter.extinfLine == 0 {
+		linter.add(lineNumber, LintError, fmt.Sprint("segment ", uri, " has no #EXTINF"))
+		return
+	}
+	if linter.endlistLine > 0 {
+		linter.add(lineNumber, LintError, fmt.Sprint("segment ", uri, " is after #EXT-X-ENDLIST"))
+	}
+	duration := linter.extinfDuration
+	linter.extinfLine = 0
+	linter.numSegments++
+	linter.totalDuration += duration
+	if linter.targetDuration >= 0 && int(math.Round(duration)) > linter.targetDuration {
+		linter.add(lineNumber, LintError, fmt.Sprint("segment duration ", duration, " is longer than the target duration ", linter.targetDuration))
+	}
+	uriPath, _, _ := strings.Cut(uri, "?")
+	extension := path.Ext(uriPath)
+	if _, ok := linter.extensions[extension]; !ok {
+		linter.extensions[extension] = lineNumber
+	}
+	if isMutedUri(uri) {
+		linter.numMuted++
+	}
+	number, ok := segmentNumber(uri)
+	if !ok {
+		return
+	}
+	if linter.previousNumber >= 0 && number != linter.previousNumber+1 {
+		linter.add(lineNumber, LintWarning, fmt.Sprint("segment number jumps from ", linter.previousNumber, " to ", number))
+	}
+	linter.previousNumber = number
+}
+
+// segmentNumber is the number of a twitch segment named {number}.ts, {number}-muted.ts or {number}-unmuted.ts.
+func segmentNumber(uri string) (int, bool) {
+	uriPath, _, _ := strings.Cut(uri, "?")
+	name, _, _ := strings.Cut(path.Base(uriPath), ".")
+	name, _, _ = strings.Cut(name, "-")
+	number, err := strconv.Atoi(name)
+	return number, err == nil
+}
+
+func isMutedUri(uri string) bool {
+	uriPath, _, _ := strings.Cut(uri, "?")
+	name, _, _ := strings.Cut(path.Base(uriPath), ".")
+	return strings.HasSuffix(name, "-muted") || strings.HasSuffix(name, "-unmuted")
+}
+
+func (linter *playlistLinter) finish() {
+	if linter.targetDuration < 0 {
+		linter.add(0, LintError, "playlist has no #EXT-X-TARGETDURATION")
+	}
+	if linter.extinfLine > 0 {
+		linter.add(linter.extinfLine, LintError, "#EXTINF is not followed by a segment uri")
+	}
+	if linter.numSegments == 0 {
+		linter.add(0, LintError, "playlist has no segments")
+	}
+	if linter.endlistLine == 0 {
+		linter.add(0, LintWarning, "playlist has no #EXT-X-ENDLIST, so players treat it as live")
+	}
+	if len(linter.extensions) > 1 {
+		extensions := []string{}
+		for extension := range linter.extensions {
+			extensions = append(extensions, fmt.Sprintf("%q", extension))
+		}
+		sort.Strings(extensions)
+		linter.add(0, LintWarning, fmt.Sprint("segments have mixed formats: ", strings.Join(extensions, ", ")))
+	}
+	for _, extension := range []string{".mp4", ".m4s"} {
+		if line, ok := linter.extensions[extension]; ok && linter.mapLine == 0 {
+			linter.add(line, LintError, fmt.Sprint(extension, " segments need an #EXT-X-MAP with the initialization section"))
+		}
+	}
+	if linter.twitchTotalLine > 0 && math.Abs(linter.twitchTotalSecs-linter.totalDuration) > 1 {
+		linter.add(linter.twitchTotalLine, LintWarning, fmt.Sprintf("%v is %.3f but the segments last %.3f seconds", strings.TrimSuffix(TagTwitchTotalSecs, ":"), linter.twitchTotalSecs, linter.totalDuration))
+	}
+	if linter.numMuted > 0 {
+		linter.add(0, LintInfo, fmt.Sprint(linter.numMuted, " segments out of ", linter.numSegments, " are muted"))
+	}
+}
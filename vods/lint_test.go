@@ -0,0 +1,80 @@
+package vods_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func hasIssue(issues []vods.LintIssue, severity vods.LintSeverity, message string) bool {
+	for _, issue := range issues {
+		if issue.Severity == severity && strings.Contains(issue.Message, message) {
+			return true
+		}
+	}
+	return false
+}
+
+const lintTwitchPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:11
+#ID3-EQUIV-TDTG:2022-09-24T20:30:12
+#EXT-X-PLAYLIST-TYPE:EVENT
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TWITCH-ELAPSED-SECS:0.000
+#EXT-X-TWITCH-TOTAL-SECS:20.500
+#EXTINF:10.000,
+0.ts
+#EXTINF:10.500,
+1-unmuted.ts
+#EXT-X-ENDLIST
+`
+
+func TestLintTwitchPlaylist(t *testing.T) {
+	issues := vods.LintMediaPlaylist([]byte(lintTwitchPlaylist))
+	assertEqual(t, len(issues), 1)
+	assertEqual(t, hasIssue(issues, vods.LintInfo, "1 segments out of 2 are muted"), true)
+}
+
+func TestLintMediaPlaylist(t *testing.T) {
+	playlist := `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXT-X-MAP:URI=""
+#EXTINF:12.000,
+0.ts
+#EXTINF:10.000,
+2.m4s
+#EXTINF:10.000,
+`
+	issues := vods.LintMediaPlaylist([]byte(playlist))
+	for _, want := range []struct {
+		severity vods.LintSeverity
+		message  string
+	}{
+		{vods.LintError, "#EXT-X-MAP has no URI"},
+		{vods.LintError, "longer than the target duration"},
+		{vods.LintError, "#EXTINF is not followed by a segment uri"},
+		{vods.LintWarning, "no #EXT-X-ENDLIST"},
+		{vods.LintWarning, "mixed formats"},
+		{vods.LintWarning, "segment number jumps from 0 to 2"},
+	} {
+		if !hasIssue(issues, want.severity, want.message) {
+			t.Fatalf("missing %v %v in %v", want.severity, want.message, issues)
+		}
+	}
+}
+
+func TestDecodeMediaPlaylistLenient(t *testing.T) {
+	mediapl, warning, err := vods.DecodeMediaPlaylistLenient([]byte(twitchPlaylist))
+	if err != nil || warning != nil {
+		t.Fatalf("got warning %v and error %v", warning, err)
+	}
+	assertEqual(t, len(mediapl.Segments), 2)
+	invalid := strings.Replace(twitchPlaylist, "TOTAL-SECS:20.500", "TOTAL-SECS:abc", 1)
+	mediapl, warning, err = vods.DecodeMediaPlaylistLenient([]byte(invalid))
+	if err != nil || warning == nil {
+		t.Fatalf("got warning %v and error %v", warning, err)
+	}
+	assertEqual(t, len(mediapl.Segments), 2)
+}
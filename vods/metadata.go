@@ -0,0 +1,148 @@
+package vods
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/grafov/m3u8"
+)
+
+// A SegmentRange is the segments from index Start up to but not including End of the decoded playlist.
+type SegmentRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// PlaylistMetadata describes how a playlist was found, so that it can be audited and found again.
+type PlaylistMetadata struct {
+	Version         string         `json:"version,omitempty"` // of govods
+	Streamer        string         `json:"streamer"`
+	VideoId         string         `json:"videoId"`
+	Source          string         `json:"source,omitempty"` // where the provided time came from, e.g. twitchtracker
+	Time            *time.Time     `json:"time,omitempty"`   // the provided start time, if any
+	Domain          string         `json:"domain,omitempty"` // e.g. https://d1m7jfoe9zdc1j.cloudfront.net/
+	UrlPath         string         `json:"urlPath,omitempty"`
+	Url             string         `json:"url,omitempty"` // the index-dvr url
+	PathScheme      string         `json:"pathScheme,omitempty"`
+	MatchedTime     *time.Time     `json:"matchedTime,omitempty"`   // the start time in the url path
+	OffsetSeconds   *int64         `json:"offsetSeconds,omitempty"` // matched time minus provided time
+	ResolvedAt      *time.Time     `json:"resolvedAt,omitempty"`
+	VanishedAt      *time.Time     `json:"vanishedAt,omitempty"` // when the playlist was first found to be gone
+	DurationSeconds float64        `json:"durationSeconds,omitempty"`
+	Segments        int            `json:"segments"`
+	ValidSegments   *int           `json:"validSegments,omitempty"` // only set if segments were validated
+	MissingSegments *int           `json:"missingSegments,omitempty"`
+	MutedSegments   int            `json:"mutedSegments"`
+	MutedRanges     []SegmentRange `json:"mutedRanges,omitempty"`
+	MissingRanges   []SegmentRange `json:"missingRanges,omitempty"`
+	Warnings        []string       `json:"warnings,omitempty"`
+}
+
+func NewPlaylistMetadata(videoData *VideoData) *PlaylistMetadata {
+	metadata := &PlaylistMetadata{Streamer: videoData.StreamerName, VideoId: videoData.VideoId}
+	if !videoData.Time.IsZero() {
+		provided := videoData.Time
+		metadata.Time = &provided
+	}
+	return metadata
+}
+
+// SetDwp records the valid DomainWithPath that was resolved at resolvedAt.
+func (metadata *PlaylistMetadata) SetDwp(dwp *DomainWithPath, resolvedAt time.Time) {
+	metadata.Domain = dwp.Domain
+	metadata.UrlPath = dwp.Path.UrlPath
+	metadata.Url = dwp.GetIndexDvrUrl()
+	metadata.PathScheme = dwp.Path.Scheme.Name()
+	matched := dwp.GetVideoData().Time
+	metadata.MatchedTime = &matched
+	if metadata.Time != nil {
+		offset := int64(matched.Sub(*metadata.Time) / time.Second)
+		metadata.OffsetSeconds = &offset
+	}
+	metadata.ResolvedAt = &resolvedAt
+}
+
+// DomainWithPath returns the DomainWithPath that the metadata was resolved at.
+func (metadata *PlaylistMetadata) DomainWithPath() (*DomainWithPath, error) {
+	if metadata.UrlPath == "" || metadata.MatchedTime == nil {
+		return nil, errors.New(fmt.Sprint("metadata of ", metadata.Streamer, " ", metadata.VideoId, " has no url path"))
+	}
+	scheme, ok := LookupPathScheme(metadata.PathScheme)
+	if !ok {
+		scheme = UnixPathScheme
+	}
+	return &DomainWithPath{
+		Domain: metadata.Domain,
+		Path: &VideoPath{
+			UrlPath:   metadata.UrlPath,
+			VideoData: &VideoData{StreamerName: metadata.Streamer, VideoId: metadata.VideoId, Time: *metadata.MatchedTime},
+			Scheme:    scheme,
+		},
+	}, nil
+}
+
+// SidecarPath is the path of the metadata of the playlist at playlistPath.
+func SidecarPath(playlistPath string) string {
+	return strings.TrimSuffix(playlistPath, ".m3u8") + ".json"
+}
+
+// RawPlaylistPath is the path of the original body of the playlist at playlistPath.
+func RawPlaylistPath(playlistPath string) string {
+	return strings.TrimSuffix(playlistPath, ".m3u8") + ".raw.m3u8"
+}
+
+func LoadPlaylistMetadata(path string) (*PlaylistMetadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &PlaylistMetadata{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+func (metadata *PlaylistMetadata) Save(path string) error {
+	return writeJsonFile(path, metadata)
+}
+
+// MutedRanges returns the ranges of segments of a muted playlist that have no audio.
+func MutedRanges(playlist *m3u8.MediaPlaylist) []SegmentRange {
+	muted := []bool{}
+	for _, segment := range playlist.Segments {
+		muted = append(muted, strings.HasSuffix(segment.URI, "-muted.ts"))
+	}
+	return toRanges(muted)
+}
+
+// MissingRanges returns the ranges of segments that are not valid.
+func MissingRanges(results []SegmentResult) []SegmentRange {
+	missing := []bool{}
+	for _, result := range results {
+		for len(missing) < result.Index {
+			missing = append(missing, false)
+		}
+		missing = append(missing, !result.Valid)
+	}
+	return toRanges(missing)
+}
+
+func toRanges(flags []bool) []SegmentRange {
+	ranges := []SegmentRange{}
+	for i, flag := range flags {
+		if !flag {
+			continue
+		}
+		if len(ranges) > 0 && ranges[len(ranges)-1].End == i {
+			ranges[len(ranges)-1].End = i + 1
+		} else {
+			ranges = append(ranges, SegmentRange{Start: i, End: i + 1})
+		}
+	}
+	return ranges
+}
@@ -0,0 +1,59 @@
+package vods_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func TestMissingRanges(t *testing.T) {
+	results := []vods.SegmentResult{}
+	for i := 0; i < 10; i++ {
+		results = append(results, vods.SegmentResult{Index: i, Valid: i < 2 || (i >= 5 && i < 9)})
+	}
+	ranges := vods.MissingRanges(results)
+	assertEqual(t, len(ranges), 2)
+	assertEqual(t, ranges[0], vods.SegmentRange{Start: 2, End: 5})
+	assertEqual(t, ranges[1], vods.SegmentRange{Start: 9, End: 10})
+}
+
+func TestPlaylistMetadataSidecar(t *testing.T) {
+	videoData := vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038929, 0).UTC()}
+	dwp, err := vods.UrlToDomainWithPath("https://d1m7jfoe9zdc1j.cloudfront.net/" + videoData.WithOffset(1).GetUrlPath(vods.UnixPathScheme) + "/chunked/index-dvr.m3u8")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	metadata := vods.NewPlaylistMetadata(&videoData)
+	metadata.SetDwp(dwp, time.Unix(1700000000, 0).UTC())
+	path := vods.SidecarPath(filepath.Join(t.TempDir(), "gmhikaru", "vod.m3u8"))
+	assertEqual(t, filepath.Base(path), "vod.json")
+	if err := metadata.Save(path); err != nil {
+		t.Fatalf(err.Error())
+	}
+	loaded, err := vods.LoadPlaylistMetadata(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, loaded.Url, dwp.GetIndexDvrUrl())
+	assertEqual(t, loaded.PathScheme, vods.UnixPathScheme.Name())
+	assertEqual(t, *loaded.OffsetSeconds, int64(1))
+	assertEqual(t, loaded.MatchedTime.Equal(videoData.Time.Add(time.Second)), true)
+}
+
+func TestPlaylistMetadataDomainWithPath(t *testing.T) {
+	videoData := vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038929, 0).UTC()}
+	dwp := &vods.DomainWithPath{Domain: "https://d1m7jfoe9zdc1j.cloudfront.net/", Path: videoData.GetVideoPath(vods.SecondsPathScheme)}
+	metadata := vods.NewPlaylistMetadata(&videoData)
+	if _, err := metadata.DomainWithPath(); err == nil {
+		t.Fatalf("expected an error without a url path")
+	}
+	metadata.SetDwp(dwp, time.Now())
+	found, err := metadata.DomainWithPath()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, found.GetIndexDvrUrl(), dwp.GetIndexDvrUrl())
+	assertEqual(t, found.Path.Scheme.Name(), vods.SecondsPathScheme.Name())
+}
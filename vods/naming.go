@@ -0,0 +1,127 @@
+package vods
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// NameFields are the values of the fields of a name template.
+type NameFields struct {
+	Streamer string
+	VideoId  string
+	Start    time.Time
+	Duration time.Duration
+	Domain   string // the host of the domain, e.g. d1m7jfoe9zdc1j.cloudfront.net
+}
+
+// DefaultNameTemplate is the name of the playlists written before name templates existed.
+const DefaultNameTemplate = "{streamer}/{streamer}_{start}_{videoid}_{duration}"
+
+const defaultStartLayout = "2006-01-02_15:04:05"
+
+// NewNameFields returns the fields of a valid DomainWithPath whose playlist lasts duration.
+func NewNameFields(dwp *DomainWithPath, duration time.Duration) NameFields {
+	videoData := dwp.GetVideoData()
+	domain := dwp.Domain
+	if parsed, err := url.Parse(dwp.Domain); err == nil && parsed.Host != "" {
+		domain = parsed.Host
+	}
+	return NameFields{
+		Streamer: videoData.StreamerName,
+		VideoId:  videoData.VideoId,
+		Start:    videoData.Time,
+		Duration: duration.Truncate(time.Second),
+		Domain:   domain,
+	}
+}
+
+// ExpandNameTemplate replaces {streamer}, {videoid}, {start}, {start:layout}, {duration} and {domain}
+// in template. The layout of start is a Go time layout. "/" separates directories.
+func ExpandNameTemplate(template string, fields NameFields) (string, error) {
+	builder := strings.Builder{}
+	rest := template
+	for {
+		open := strings.Index(rest, "{")
+		if open < 0 {
+			break
+		}
+		builder.WriteString(rest[:open])
+		end := strings.Index(rest[open:], "}")
+		if end < 0 {
+			return "", errors.New(fmt.Sprint("name template ", template, " has an unclosed {"))
+		}
+		field := rest[open+1 : open+end]
+		name, layout, hasLayout := strings.Cut(field, ":")
+		if hasLayout && name != "start" {
+			return "", errors.New(fmt.Sprint("name template field ", name, " has no layout"))
+		}
+		switch name {
+		case "streamer":
+			builder.WriteString(fields.Streamer)
+		case "videoid":
+			builder.WriteString(fields.VideoId)
+		case "start":
+			if !hasLayout {
+				layout = defaultStartLayout
+			}
+			builder.WriteString(fields.Start.Format(layout))
+		case "duration":
+			builder.WriteString(fields.Duration.String())
+		case "domain":
+			builder.WriteString(fields.Domain)
+		default:
+			return "", errors.New(fmt.Sprint("name template field {", field, "} is not one of streamer, videoid, start, duration or domain"))
+		}
+		rest = rest[open+end+1:]
+	}
+	builder.WriteString(rest)
+	return builder.String(), nil
+}
+
+// SanitizePath makes every "/" separated element of path a file name that is valid on
+// Windows, macOS and Linux, and joins them with the separator of this system.
+func SanitizePath(path string) string {
+	elements := []string{}
+	for _, element := range strings.Split(path, "/") {
+		elements = append(elements, SanitizeFilename(element))
+	}
+	return filepath.Join(elements...)
+}
+
+var reservedFilenames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+const maxFilenameBytes = 200
+
+// SanitizeFilename replaces the characters that some filesystems reject with "-",
+// and avoids names that are reserved on Windows or would be a relative directory.
+func SanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '-'
+		}
+		return r
+	}, name)
+	for len(name) > maxFilenameBytes {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	// Windows drops trailing dots and spaces
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if reservedFilenames[strings.ToUpper(base)] {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,43 @@
+package vods_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func TestExpandNameTemplate(t *testing.T) {
+	fields := vods.NameFields{
+		Streamer: "gmhikaru",
+		VideoId:  "47198535725",
+		Start:    time.Unix(1664038929, 0).UTC(),
+		Duration: 3*time.Hour + 2*time.Second,
+		Domain:   "d1m7jfoe9zdc1j.cloudfront.net",
+	}
+	name, err := vods.ExpandNameTemplate(vods.DefaultNameTemplate, fields)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, name, "gmhikaru/gmhikaru_2022-09-24_17:02:09_47198535725_3h0m2s")
+	name, err = vods.ExpandNameTemplate("{domain}/{start:2006-01}/{videoid}", fields)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, name, "d1m7jfoe9zdc1j.cloudfront.net/2022-09/47198535725")
+	for _, template := range []string{"{streamer", "{unknown}", "{videoid:2006}"} {
+		if _, err := vods.ExpandNameTemplate(template, fields); err == nil {
+			t.Fatalf("expected an error for %v", template)
+		}
+	}
+}
+
+func TestSanitizePath(t *testing.T) {
+	assertEqual(t, vods.SanitizePath("gmhikaru/gmhikaru_2022-09-24_17:02:09"), filepath.Join("gmhikaru", "gmhikaru_2022-09-24_17-02-09"))
+	assertEqual(t, vods.SanitizePath("../a?b"), filepath.Join("_", "a-b"))
+	assertEqual(t, vods.SanitizeFilename("con.m3u8"), "_con.m3u8")
+	assertEqual(t, vods.SanitizeFilename("name. "), "name")
+	assertEqual(t, len(vods.SanitizeFilename(strings.Repeat("é", 200))), 200)
+}
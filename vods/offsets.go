@@ -0,0 +1,72 @@
+package vods
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"time"
+)
+
+// An OffsetHistogram counts how many seconds the time in valid url paths differed from the provided time.
+type OffsetHistogram map[int]int
+
+// OffsetStats holds an OffsetHistogram for each source of start times, e.g. sullygnome.
+type OffsetStats struct {
+	Sources map[string]OffsetHistogram `json:"sources"`
+}
+
+// LoadOffsetStats reads the stats at path. A missing file gives empty stats.
+func LoadOffsetStats(path string) (*OffsetStats, error) {
+	stats := &OffsetStats{Sources: map[string]OffsetHistogram{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, stats); err != nil {
+		return nil, err
+	}
+	if stats.Sources == nil {
+		stats.Sources = map[string]OffsetHistogram{}
+	}
+	return stats, nil
+}
+
+func (stats *OffsetStats) Save(path string) error {
+	return writeJsonFile(path, stats)
+}
+
+// Record adds the difference between the provided time and the time that was actually found.
+func (stats *OffsetStats) Record(source string, provided time.Time, matched time.Time) {
+	histogram, ok := stats.Sources[source]
+	if !ok {
+		histogram = OffsetHistogram{}
+		stats.Sources[source] = histogram
+	}
+	histogram[int(matched.Sub(provided).Round(time.Second)/time.Second)]++
+}
+
+func (stats *OffsetStats) Histogram(source string) OffsetHistogram {
+	return stats.Sources[source]
+}
+
+// Order sorts offsets from most to least observed. Ties keep their order.
+func (histogram OffsetHistogram) Order(offsets []int) []int {
+	result := append([]int{}, offsets...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return histogram[result[i]] > histogram[result[j]]
+	})
+	return result
+}
+
+// OffsetRange returns the offsets from first to last inclusive.
+func OffsetRange(first int, last int) []int {
+	offsets := []int{}
+	for offset := first; offset <= last; offset++ {
+		offsets = append(offsets, offset)
+	}
+	return offsets
+}
@@ -0,0 +1,68 @@
+package vods_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func TestOffsetStatsOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offsets.json")
+	stats, err := vods.LoadOffsetStats(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	offsets := vods.OffsetRange(-1, 58)
+	// without recorded offsets, the order is kept
+	assertEqual(t, stats.Histogram("streamscharts").Order(offsets)[0], -1)
+
+	provided := time.Unix(1664038929, 0)
+	stats.Record("streamscharts", provided, provided.Add(30*time.Second))
+	stats.Record("streamscharts", provided, provided.Add(30*time.Second+400*time.Millisecond))
+	stats.Record("streamscharts", provided, provided.Add(5*time.Second))
+	stats.Record("sullygnome", provided, provided.Add(-1*time.Second))
+	ordered := stats.Histogram("streamscharts").Order(offsets)
+	assertEqual(t, len(ordered), len(offsets))
+	assertEqual(t, ordered[0], 30)
+	assertEqual(t, ordered[1], 5)
+	assertEqual(t, ordered[2], -1)
+	assertEqual(t, ordered[3], 0)
+	assertEqual(t, offsets[0], -1)
+
+	// the most observed offset is the first candidate of every domain
+	videoData := vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: provided}
+	domains := []string{"https://vod-secure.twitch.tv/", "https://vod-metro.twitch.tv/"}
+	for _, domainWithPaths := range videoData.GetDomainWithPathsListFromOffsets(domains, ordered, vods.UnixPathScheme) {
+		assertEqual(t, domainWithPaths.ToListOfDomainWithPath()[0].Path.UrlPath, videoData.WithOffset(30).GetUrlPath(vods.UnixPathScheme))
+	}
+
+	if err := stats.Save(path); err != nil {
+		t.Fatalf(err.Error())
+	}
+	loaded, err := vods.LoadOffsetStats(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, len(loaded.Sources), 2)
+	assertEqual(t, loaded.Histogram("streamscharts")[30], 2)
+	assertEqual(t, loaded.Histogram("streamscharts")[5], 1)
+	assertEqual(t, loaded.Histogram("sullygnome")[-1], 1)
+	reordered := loaded.Histogram("streamscharts").Order(offsets)
+	for i := range ordered {
+		assertEqual(t, reordered[i], ordered[i])
+	}
+	assertEqual(t, loaded.Histogram("sullygnome").Order(vods.OffsetRange(-1, 0))[0], -1)
+}
+
+func TestLoadOffsetStatsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offsets.json")
+	if err := os.WriteFile(path, []byte(`{"sources": {"sullygnome": []}}`), 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if _, err := vods.LoadOffsetStats(path); err == nil {
+		t.Fatalf("loaded invalid offsets")
+	}
+}
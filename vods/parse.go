@@ -3,8 +3,6 @@ package vods
 import (
 	"bytes"
 	"context"
-	"crypto/sha1"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -15,7 +13,6 @@ import (
 	"strings"
 	"time"
 
-	firstnonerr "github.com/auoie/first-nonerr"
 	"github.com/grafov/m3u8"
 )
 
@@ -45,6 +42,14 @@ type VideoData struct {
 type VideoPath struct {
 	UrlPath   string // e.g. {hash}_{streamername}_{videoid}_{unixtime}
 	VideoData *VideoData
+	Scheme    PathScheme
+}
+
+// WeightedPaths is a list of candidate paths in priority order.
+// Paths with a lower weight are tried later.
+type WeightedPaths struct {
+	Paths  []*VideoPath
+	Weight float64
 }
 type DomainWithPath struct {
 	Domain string // e.g. https://d1m7jfoe9zdc1j.cloudfront.net/
@@ -61,19 +66,6 @@ type ValidDwpResponse struct {
 	Body []byte
 }
 
-type urlIndexResponse struct {
-	index int
-	valid bool
-}
-
-func retryOnError[T any](doer func() (T, error)) (T, error) {
-	res, err := doer()
-	if err != nil {
-		return doer()
-	}
-	return res, err
-}
-
 // e.g. c5992ececce7bd7d350d_gmhikaru_47198535725_1664038929
 func UrlPathToVideoData(urlPath string) (*VideoData, error) {
 	allUnderscoreIndices := []int{}
@@ -111,7 +103,7 @@ func UrlToDomainWithPath(urlStr string) (*DomainWithPath, error) {
 		return nil, errors.New("url is not valid")
 	}
 	mainPart := pathParts[1]
-	videoData, err := UrlPathToVideoData(mainPart)
+	scheme, videoData, err := DetectPathScheme(mainPart)
 	if err != nil {
 		return nil, err
 	}
@@ -120,6 +112,7 @@ func UrlToDomainWithPath(urlStr string) (*DomainWithPath, error) {
 		Path: &VideoPath{
 			UrlPath:   mainPart,
 			VideoData: videoData,
+			Scheme:    scheme,
 		},
 	}
 	return &result, nil
@@ -130,57 +123,82 @@ func (videoData *VideoData) String() string {
 	return strings.Join(values, "_")
 }
 
-func (videoData *VideoData) GetVideoPath(toUnix bool) *VideoPath {
-	return &VideoPath{UrlPath: videoData.GetUrlPath(toUnix), VideoData: videoData}
+func (videoData *VideoData) GetVideoPath(scheme PathScheme) *VideoPath {
+	return &VideoPath{UrlPath: videoData.GetUrlPath(scheme), VideoData: videoData, Scheme: scheme}
 }
 
-func (videoData *VideoData) GetUrlPath(toUnix bool) string {
-	if toUnix {
-		return videoData.getUrlTimeUnix()
-	} else {
-		return videoData.getUrlTimeSecond()
-	}
+func (videoData *VideoData) GetUrlPath(scheme PathScheme) string {
+	return scheme.UrlPath(videoData)
 }
 
-func timeToUnixString(t time.Time) string {
-	return strconv.FormatInt(t.Unix(), 10)
+func (videoData *VideoData) WithOffset(seconds int) *VideoData {
+	return &VideoData{
+		StreamerName: videoData.StreamerName,
+		VideoId:      videoData.VideoId,
+		Time:         videoData.Time.Add(time.Second * time.Duration(seconds)),
+	}
 }
 
-func timeToSecond(t time.Time) string {
-	return strconv.Itoa(t.Second())
+func (videoData *VideoData) GetDomainWithPathsList(domains []string, seconds int, scheme PathScheme) []*DomainWithPaths {
+	return videoData.GetDomainWithPathsListFromOffsets(domains, OffsetRange(0, seconds-1), scheme)
 }
 
-func (videoData *VideoData) getUrlTimeUnix() string {
-	return videoData.getUrlPath(timeToUnixString)
+// The paths of each domain are tried in the order of offsets.
+func (videoData *VideoData) GetDomainWithPathsListFromOffsets(domains []string, offsets []int, scheme PathScheme) []*DomainWithPaths {
+	return NewDomainWithPathsList(domains, videoData.GetVideoPaths(offsets, scheme))
 }
 
-func (videoData *VideoData) getUrlTimeSecond() string {
-	return videoData.getUrlPath(timeToSecond)
+func (videoData *VideoData) GetVideoPaths(offsets []int, scheme PathScheme) []*VideoPath {
+	videoPaths := []*VideoPath{}
+	for _, offset := range offsets {
+		videoPaths = append(videoPaths, videoData.WithOffset(offset).GetVideoPath(scheme))
+	}
+	return videoPaths
 }
 
-func (videoData *VideoData) getUrlPath(timetoString func(time.Time) string) string {
-	baseUrl := fmt.Sprint(videoData.StreamerName, "_", videoData.VideoId, "_", timetoString(videoData.Time))
-	hasher := sha1.New()
-	io.WriteString(hasher, baseUrl)
-	hash := hex.EncodeToString(hasher.Sum(nil))
-	hashedBaseUrl := hash[:20]
-	formattedBaseUrl := hashedBaseUrl + "_" + baseUrl
-	return formattedBaseUrl
+// GetRegisteredVideoPaths interleaves the paths of every registered PathScheme by their weights.
+func (videoData *VideoData) GetRegisteredVideoPaths(offsets []int) []*VideoPath {
+	lists := []WeightedPaths{}
+	for _, registered := range RegisteredPathSchemes() {
+		lists = append(lists, WeightedPaths{Paths: videoData.GetVideoPaths(offsets, registered.Scheme), Weight: registered.Weight})
+	}
+	return InterleavePaths(lists...)
 }
 
-func (videoData *VideoData) WithOffset(seconds int) *VideoData {
-	return &VideoData{
-		StreamerName: videoData.StreamerName,
-		VideoId:      videoData.VideoId,
-		Time:         videoData.Time.Add(time.Second * time.Duration(seconds)),
+// InterleavePaths merges lists of paths into a single priority order.
+// The path at rank r of a list with weight w is placed at (r + 1) / w, so a list with weight 0.25
+// contributes one path for every four paths of a list with weight 1.
+// Duplicate url paths are only kept the first time they appear.
+func InterleavePaths(lists ...WeightedPaths) []*VideoPath {
+	type rankedPath struct {
+		path *VideoPath
+		key  float64
+	}
+	rankedPaths := []rankedPath{}
+	for _, list := range lists {
+		if list.Weight <= 0 {
+			continue
+		}
+		for rank, path := range list.Paths {
+			rankedPaths = append(rankedPaths, rankedPath{path: path, key: float64(rank+1) / list.Weight})
+		}
+	}
+	sort.SliceStable(rankedPaths, func(i, j int) bool {
+		return rankedPaths[i].key < rankedPaths[j].key
+	})
+	seen := map[string]bool{}
+	result := []*VideoPath{}
+	for _, rankedPath := range rankedPaths {
+		if seen[rankedPath.path.UrlPath] {
+			continue
+		}
+		seen[rankedPath.path.UrlPath] = true
+		result = append(result, rankedPath.path)
 	}
+	return result
 }
 
-func (videoData *VideoData) GetDomainWithPathsList(domains []string, seconds int, toUnix bool) []*DomainWithPaths {
-	videoPaths := []*VideoPath{}
-	for i := 0; i < seconds; i++ {
-		videoPaths = append(videoPaths, videoData.WithOffset(i).GetVideoPath(toUnix))
-	}
+func NewDomainWithPathsList(domains []string, videoPaths []*VideoPath) []*DomainWithPaths {
 	domainWithPathsList := []*DomainWithPaths{}
 	for _, domain := range domains {
 		domainWithPathsList = append(domainWithPathsList, &DomainWithPaths{domain: domain, paths: videoPaths})
@@ -197,40 +215,14 @@ func (domainWithPaths *DomainWithPaths) ToListOfDomainWithPath() []*DomainWithPa
 	return result
 }
 
+// GetFirstValidDWP returns the first valid domain with path of domainWithPaths.
+// It searches with DefaultSearchPlanner, so it is bound by the same limits as GetFirstValidDwp.
 func (domainWithPaths *DomainWithPaths) GetFirstValidDWP(ctx context.Context, client *http.Client) (*ValidDwpResponse, error) {
-	domainWithPathList := domainWithPaths.ToListOfDomainWithPath()
-	if len(domainWithPathList) < 1 {
-		return nil, errors.New("no urls")
-	}
-	// establish TCP connection for reuse
-	// https://groups.google.com/g/golang-nuts/c/5T5aiDRl_cw/m/zYPGtCOYBwAJ
-	firstDomainWithPath := domainWithPathList[0]
-	body, err := firstDomainWithPath.GetM3U8Body(ctx, client)
-	if err == nil {
-		return &ValidDwpResponse{Dwp: firstDomainWithPath, Body: body}, nil
-	} else if len(domainWithPathList) == 1 {
-		return nil, err
-	}
-	// reuse with other requests
-	restDomainWithPathList := domainWithPathList[1:]
-	return firstnonerr.GetFirstNonError(
-		ctx,
-		restDomainWithPathList,
-		0,
-		func(ctx context.Context, item *DomainWithPath) (*ValidDwpResponse, error) {
-			body, err := item.GetM3U8Body(ctx, client)
-			return &ValidDwpResponse{Dwp: item, Body: body}, err
-		})
+	return DefaultSearchPlanner.GetFirstValidDwp(ctx, []*DomainWithPaths{domainWithPaths}, client)
 }
 
 func GetFirstValidDwp(ctx context.Context, domainWithPathsList []*DomainWithPaths, client *http.Client) (*ValidDwpResponse, error) {
-	return firstnonerr.GetFirstNonError(
-		ctx,
-		domainWithPathsList,
-		0,
-		func(ctx context.Context, item *DomainWithPaths) (*ValidDwpResponse, error) {
-			return item.GetFirstValidDWP(ctx, client)
-		})
+	return DefaultSearchPlanner.GetFirstValidDwp(ctx, domainWithPathsList, client)
 }
 
 func (d *DomainWithPath) GetDomain() string {
@@ -269,25 +261,29 @@ func (d *DomainWithPath) MakePathsExplicit(playlist *m3u8.MediaPlaylist) *m3u8.M
 }
 
 func (d *DomainWithPath) GetM3U8Body(ctx context.Context, client *http.Client) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.GetIndexDvrUrl(), nil)
+	return GetPlaylistBody(ctx, client, d.GetIndexDvrUrl())
+}
+
+// GetPlaylistBody downloads the playlist at url. It returns a StatusError if the status isn't 200.
+func GetPlaylistBody(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := retryOnError(func() (*http.Response, error) {
-		return client.Do(req)
-	})
+	resp, err := doRequest(client, req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		return nil, errors.New(fmt.Sprint("status code is ", resp.StatusCode))
+		io.Copy(io.Discard, resp.Body)
+		return nil, &StatusError{StatusCode: resp.StatusCode}
 	}
 	return io.ReadAll(resp.Body)
 }
 
 func DecodeMediaPlaylistFilterNilSegments(data []byte, strict bool) (*m3u8.MediaPlaylist, error) {
-	p, listType, err := m3u8.Decode(*bytes.NewBuffer(data), strict)
+	p, listType, err := m3u8.DecodeWith(*bytes.NewBuffer(data), strict, TwitchTagDecoders())
 	if err != nil {
 		return nil, err
 	}
@@ -305,6 +301,20 @@ func DecodeMediaPlaylistFilterNilSegments(data []byte, strict bool) (*m3u8.Media
 	return mediapl, nil
 }
 
+// DecodeMediaPlaylistLenient decodes strictly, and if that fails, decodes again without the strict checks.
+// If it had to fall back, the error of the strict decoding is returned as warning.
+func DecodeMediaPlaylistLenient(data []byte) (mediapl *m3u8.MediaPlaylist, warning error, err error) {
+	mediapl, warning = DecodeMediaPlaylistFilterNilSegments(data, true)
+	if warning == nil {
+		return mediapl, nil, nil
+	}
+	mediapl, err = DecodeMediaPlaylistFilterNilSegments(data, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mediapl, warning, nil
+}
+
 func getMutedURI(segmentUri string) string {
 	if strings.Contains(segmentUri, "unmuted") {
 		start := strings.Index(segmentUri, "-")
@@ -323,6 +333,17 @@ func MuteMediaSegments(playlist *m3u8.MediaPlaylist) []*m3u8.MediaSegment {
 	return nonnilSegments
 }
 
+// CountMutedSegments counts the segments of a muted playlist that have no audio.
+func CountMutedSegments(playlist *m3u8.MediaPlaylist) int {
+	count := 0
+	for _, segment := range playlist.Segments {
+		if strings.HasSuffix(segment.URI, "-muted.ts") {
+			count++
+		}
+	}
+	return count
+}
+
 func GetMediaPlaylistDuration(mediapl *m3u8.MediaPlaylist) time.Duration {
 	duration := 0.0
 	for _, segment := range mediapl.Segments {
@@ -331,21 +352,23 @@ func GetMediaPlaylistDuration(mediapl *m3u8.MediaPlaylist) time.Duration {
 	return time.Duration(duration * float64(time.Second))
 }
 
-func GetValidSegments(mediapl *m3u8.MediaPlaylist, concurrent int, client *http.Client) []*m3u8.MediaSegment {
+// ValidateSegments checks the segments of the playlist. See SegmentValidator for cancellation.
+func ValidateSegments(ctx context.Context, mediapl *m3u8.MediaPlaylist, validator SegmentValidator, client *http.Client, prober *Prober) ([]SegmentResult, error) {
 	urls := []string{}
 	for _, segment := range mediapl.Segments {
 		urls = append(urls, segment.URI)
 	}
-	sortedValidIndices := getSortedIndicesOfValidUrls(urls, concurrent, client)
-	segments := []*m3u8.MediaSegment{}
-	for _, validIndex := range sortedValidIndices {
-		segments = append(segments, mediapl.Segments[validIndex])
-	}
-	return segments
+	return validator.Validate(ctx, urls, client, prober)
 }
 
-func GetMediaPlaylistWithValidSegments(rawPlaylist *m3u8.MediaPlaylist, concurrent int, client *http.Client) (*m3u8.MediaPlaylist, error) {
-	validSegments := GetValidSegments(rawPlaylist, concurrent, client)
+// GetMediaPlaylistWithValidSegments returns a playlist with only the segments of the valid results.
+func GetMediaPlaylistWithValidSegments(rawPlaylist *m3u8.MediaPlaylist, results []SegmentResult) (*m3u8.MediaPlaylist, error) {
+	validSegments := []*m3u8.MediaSegment{}
+	for _, result := range results {
+		if result.Valid {
+			validSegments = append(validSegments, rawPlaylist.Segments[result.Index])
+		}
+	}
 	numValidSegments := uint(len(validSegments))
 	mediapl, err := m3u8.NewMediaPlaylist(rawPlaylist.WinSize(), numValidSegments)
 	if err != nil {
@@ -357,74 +380,15 @@ func GetMediaPlaylistWithValidSegments(rawPlaylist *m3u8.MediaPlaylist, concurre
 	mediapl.TargetDuration = rawPlaylist.TargetDuration
 	mediapl.MediaType = rawPlaylist.MediaType
 	mediapl.Closed = rawPlaylist.Closed
-	return mediapl, err
-}
-
-const clearLine = "\033[2K"
-
-func getSortedIndicesOfValidUrls(urls []string, concurrent int, client *http.Client) []int {
-	validIndices := []int{}
-	validIndicesCh := make(chan urlIndexResponse)
-	requestIndicesCh := make(chan int)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	for i := 0; i < concurrent; i++ {
-		go func() {
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case requestIndex := <-requestIndicesCh:
-					url := urls[requestIndex]
-					valid := urlIsValid(url, client)
-					if valid {
-						validIndicesCh <- urlIndexResponse{index: requestIndex, valid: true}
-					} else {
-						validIndicesCh <- urlIndexResponse{index: requestIndex, valid: false}
-					}
-				}
-			}
-		}()
-	}
-	go func() {
-		for i := 0; i < len(urls); i++ {
-			select {
-			case <-ctx.Done():
-				return
-			case requestIndicesCh <- i:
-			}
-		}
-	}()
-	doneCount := 0
-Loop:
-	for range urls {
-		select {
-		case <-ctx.Done():
-			break Loop
-		case response := <-validIndicesCh:
-			doneCount++
-			fmt.Print(clearLine)
-			fmt.Print("\r")
-			fmt.Print(fmt.Sprint("Processed ", doneCount, " segments out of ", len(urls)))
-			if response.valid {
-				validIndices = append(validIndices, response.index)
-			}
+	mediapl.Custom = rawPlaylist.Custom
+	numSegments := 0
+	for _, segment := range rawPlaylist.Segments {
+		if segment != nil {
+			numSegments++
 		}
 	}
-	fmt.Println()
-	sort.Slice(validIndices, func(i, j int) bool {
-		return validIndices[i] < validIndices[j]
-	})
-	return validIndices
-}
-
-func urlIsValid(url string, client *http.Client) bool {
-	resp, err := retryOnError(func() (*http.Response, error) {
-		return client.Get(url)
-	})
-	if err != nil {
-		return false
+	if len(validSegments) < numSegments {
+		mediapl.Custom = withTotalSecs(rawPlaylist.Custom, validSegments)
 	}
-	defer resp.Body.Close()
-	return resp.StatusCode == 200
+	return mediapl, err
 }
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/auoie/goVods/vods"
+	"github.com/grafov/m3u8"
 )
 
 func assertEqual[T comparable](t testing.TB, got, want T) {
@@ -38,3 +39,32 @@ func TestUrlToDomainWithPath(t *testing.T) {
 	assertEqual(t, *result.Path.VideoData, vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038929, 0)})
 	assertEqual(t, result.Path.UrlPath, "c5992ececce7bd7d350d_gmhikaru_47198535725_1664038929")
 }
+
+func TestGetRegisteredVideoPaths(t *testing.T) {
+	videoData := vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038929, 0)}
+	offsets := vods.OffsetRange(-1, 59)
+	result := videoData.GetRegisteredVideoPaths(offsets)
+	assertEqual(t, len(result), 61+60) // the seconds paths for offsets -1 and 59 are the same
+	assertEqual(t, result[0].Scheme.Name(), vods.UnixPathScheme.Name())
+	assertEqual(t, result[3].Scheme.Name(), vods.UnixPathScheme.Name())
+	assertEqual(t, result[4].Scheme.Name(), vods.SecondsPathScheme.Name())
+	assertEqual(t, result[4].UrlPath, videoData.WithOffset(-1).GetUrlPath(vods.SecondsPathScheme))
+}
+
+func TestUnixPathScheme(t *testing.T) {
+	videoData := vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038929, 0)}
+	assertEqual(t, videoData.GetUrlPath(vods.UnixPathScheme), "c5992ececce7bd7d350d_gmhikaru_47198535725_1664038929")
+}
+
+func TestCountMutedSegments(t *testing.T) {
+	mediapl, err := m3u8.NewMediaPlaylist(0, 3)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	mediapl.Append("0.ts", 10, "")
+	mediapl.Append("1-unmuted.ts", 10, "")
+	mediapl.Append("2-muted.ts", 10, "")
+	vods.MuteMediaSegments(mediapl)
+	assertEqual(t, mediapl.Segments[1].URI, "1-muted.ts")
+	assertEqual(t, vods.CountMutedSegments(mediapl), 2)
+}
@@ -0,0 +1,136 @@
+package vods
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+var (
+	ErrNoCandidates = errors.New("no urls")
+	ErrRequestLimit = errors.New("request limit reached before a valid url was found")
+)
+
+// A SearchPlanner bounds the requests made while searching for a valid DomainWithPath.
+// A zero value field means no limit.
+type SearchPlanner struct {
+	Concurrency int      // maximum number of requests in flight across all domains
+	PerDomain   int      // maximum number of requests in flight to a single domain
+	MaxRequests int      // maximum number of requests for a single search
+	Prober      *Prober  // if set, candidates are probed and only the body of the valid one is downloaded
+	Reporter    Reporter // receives candidate and playlist events, may be nil
+}
+
+var DefaultSearchPlanner = SearchPlanner{Concurrency: 64, PerDomain: 16}
+
+type plannerResponse struct {
+	queueIndex int
+	dwp        *DomainWithPath
+	body       []byte
+	err        error
+}
+
+// GetFirstValidDwp tries the paths of every domain in rank order, going round robin across the domains.
+// As soon as one path is valid, all outstanding requests are cancelled.
+// It returns once every request it started has finished.
+func (planner SearchPlanner) GetFirstValidDwp(ctx context.Context, domainWithPathsList []*DomainWithPaths, client *http.Client) (*ValidDwpResponse, error) {
+	queues := [][]*DomainWithPath{}
+	numCandidates := 0
+	for _, domainWithPaths := range domainWithPathsList {
+		queue := domainWithPaths.ToListOfDomainWithPath()
+		queues = append(queues, queue)
+		numCandidates += len(queue)
+	}
+	if numCandidates == 0 {
+		return nil, ErrNoCandidates
+	}
+	reporter := reporterOrNop(planner.Reporter)
+	ctx, cancel := context.WithCancel(ctx)
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	defer cancel()
+	responses := make(chan plannerResponse)
+	inFlight := make([]int, len(queues))
+	totalInFlight := 0
+	launched := 0
+	nextQueue := 0
+	var lastErr error
+	for {
+		for planner.canLaunch(totalInFlight, launched) {
+			queueIndex := planner.pickQueue(queues, inFlight, nextQueue)
+			if queueIndex < 0 {
+				break
+			}
+			dwp := queues[queueIndex][0]
+			queues[queueIndex] = queues[queueIndex][1:]
+			inFlight[queueIndex]++
+			totalInFlight++
+			launched++
+			nextQueue = (queueIndex + 1) % len(queues)
+			reporter.Report(Event{Kind: CandidateTried, Dwp: dwp})
+			wg.Add(1)
+			go func(queueIndex int) {
+				defer wg.Done()
+				body, err := planner.check(ctx, client, dwp)
+				select {
+				case <-ctx.Done():
+				case responses <- plannerResponse{queueIndex: queueIndex, dwp: dwp, body: body, err: err}:
+				}
+			}(queueIndex)
+		}
+		if totalInFlight == 0 {
+			if launched < numCandidates {
+				return nil, ErrRequestLimit
+			}
+			return nil, lastErr
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case response := <-responses:
+			if response.err == nil {
+				reporter.Report(Event{Kind: PlaylistFound, Dwp: response.dwp})
+				return &ValidDwpResponse{Dwp: response.dwp, Body: response.body}, nil
+			}
+			reporter.Report(Event{Kind: CandidateFailed, Dwp: response.dwp, Err: response.err})
+			// once a candidate could not be checked, the search is inconclusive rather than a miss
+			if lastErr == nil || !IsThrottled(lastErr) {
+				lastErr = response.err
+			}
+			totalInFlight--
+			inFlight[response.queueIndex]--
+		}
+	}
+}
+
+func (planner SearchPlanner) check(ctx context.Context, client *http.Client, dwp *DomainWithPath) ([]byte, error) {
+	if planner.Prober != nil {
+		if err := planner.Prober.Check(ctx, client, dwp.GetIndexDvrUrl()); err != nil {
+			return nil, err
+		}
+	}
+	return dwp.GetM3U8Body(ctx, client)
+}
+
+func (planner SearchPlanner) canLaunch(totalInFlight int, launched int) bool {
+	if planner.Concurrency > 0 && totalInFlight >= planner.Concurrency {
+		return false
+	}
+	return planner.MaxRequests <= 0 || launched < planner.MaxRequests
+}
+
+// pickQueue returns the first queue starting from nextQueue that has paths left and is below the per domain limit.
+func (planner SearchPlanner) pickQueue(queues [][]*DomainWithPath, inFlight []int, nextQueue int) int {
+	for i := range queues {
+		queueIndex := (nextQueue + i) % len(queues)
+		if len(queues[queueIndex]) == 0 {
+			continue
+		}
+		if planner.PerDomain > 0 && inFlight[queueIndex] >= planner.PerDomain {
+			continue
+		}
+		return queueIndex
+	}
+	return -1
+}
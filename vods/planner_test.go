@@ -0,0 +1,99 @@
+package vods_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+// inFlight records the maximum number of requests that were in flight at the same time, in total and by host.
+type inFlight struct {
+	mu        sync.Mutex
+	started   int
+	total     int
+	maxTotal  int
+	byHost    map[string]int
+	maxByHost map[string]int
+}
+
+func newInFlight() *inFlight {
+	return &inFlight{byHost: map[string]int{}, maxByHost: map[string]int{}}
+}
+
+func (f *inFlight) start(host string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.started++
+	f.total++
+	f.byHost[host]++
+	if f.total > f.maxTotal {
+		f.maxTotal = f.total
+	}
+	if f.byHost[host] > f.maxByHost[host] {
+		f.maxByHost[host] = f.byHost[host]
+	}
+}
+
+func (f *inFlight) end(host string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.total--
+	f.byHost[host]--
+}
+
+// newPlaylistServer serves a playlist at the index-dvr url of validPath and 403 everywhere else.
+func newPlaylistServer(validPath string, requests *inFlight) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.start(r.Host)
+		time.Sleep(5 * time.Millisecond)
+		requests.end(r.Host)
+		if r.URL.Path == "/"+validPath+"/chunked/index-dvr.m3u8" {
+			w.Write([]byte("#EXTM3U\n"))
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+	}))
+}
+
+func TestSearchPlannerConcurrency(t *testing.T) {
+	videoData := vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038929, 0)}
+	validPath := videoData.WithOffset(40).GetUrlPath(vods.UnixPathScheme)
+	requests := newInFlight()
+	server := newPlaylistServer(validPath, requests)
+	domains := []string{server.URL + "/", strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/"}
+	planner := vods.SearchPlanner{Concurrency: 4, PerDomain: 2}
+	result, err := planner.GetFirstValidDwp(context.Background(), videoData.GetDomainWithPathsList(domains, 60, vods.UnixPathScheme), server.Client())
+	if err != nil {
+		server.Close()
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, result.Dwp.Path.UrlPath, validPath)
+	server.Close()
+	if requests.maxTotal > 4 {
+		t.Fatalf("%v requests were in flight with a concurrency limit of 4", requests.maxTotal)
+	}
+	assertEqual(t, len(requests.maxByHost), 2)
+	for host, maxInFlight := range requests.maxByHost {
+		if maxInFlight > 2 {
+			t.Fatalf("%v requests were in flight to %v with a per domain limit of 2", maxInFlight, host)
+		}
+	}
+}
+
+func TestSearchPlannerMaxRequests(t *testing.T) {
+	videoData := vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038929, 0)}
+	server := newPlaylistServer(videoData.WithOffset(40).GetUrlPath(vods.UnixPathScheme), newInFlight())
+	defer server.Close()
+	planner := vods.SearchPlanner{MaxRequests: 10}
+	_, err := planner.GetFirstValidDwp(context.Background(), videoData.GetDomainWithPathsList([]string{server.URL + "/"}, 60, vods.UnixPathScheme), server.Client())
+	if !errors.Is(err, vods.ErrRequestLimit) {
+		t.Fatalf(`got %v want %v`, err, vods.ErrRequestLimit)
+	}
+}
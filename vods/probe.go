@@ -0,0 +1,144 @@
+package vods
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// A ProbeMethod is how a Prober checks that a url exists.
+type ProbeMethod int
+
+const (
+	ProbeHead  ProbeMethod = iota // HEAD request, no body is sent
+	ProbeRange                    // GET request for the first byte only
+	ProbeGet                      // GET request for the whole body
+)
+
+func (method ProbeMethod) String() string {
+	switch method {
+	case ProbeHead:
+		return "head"
+	case ProbeRange:
+		return "range"
+	case ProbeGet:
+		return "get"
+	}
+	return fmt.Sprint("ProbeMethod(", int(method), ")")
+}
+
+func ParseProbeMethod(name string) (ProbeMethod, error) {
+	for _, method := range []ProbeMethod{ProbeHead, ProbeRange, ProbeGet} {
+		if method.String() == name {
+			return method, nil
+		}
+	}
+	return ProbeGet, errors.New(fmt.Sprint("unknown probe method ", name, ", expected head, range or get"))
+}
+
+// A Prober checks whether urls exist without downloading them.
+// When a domain rejects the method, the prober falls back to the next method for that domain,
+// from HEAD to a ranged GET to a full GET.
+type Prober struct {
+	Method    ProbeMethod
+	mu        sync.Mutex
+	fallbacks map[string]ProbeMethod // host -> method
+}
+
+func NewProber(method ProbeMethod) *Prober {
+	return &Prober{Method: method, fallbacks: map[string]ProbeMethod{}}
+}
+
+func (prober *Prober) methodFor(host string) ProbeMethod {
+	prober.mu.Lock()
+	defer prober.mu.Unlock()
+	if method, ok := prober.fallbacks[host]; ok {
+		return method
+	}
+	return prober.Method
+}
+
+func (prober *Prober) fallBack(host string, from ProbeMethod) {
+	prober.mu.Lock()
+	defer prober.mu.Unlock()
+	if method, ok := prober.fallbacks[host]; !ok || method <= from {
+		prober.fallbacks[host] = from + 1
+	}
+}
+
+// rejectsMethod reports whether a response means the server doesn't support the probe, rather than the url not existing.
+func rejectsMethod(method ProbeMethod, statusCode int) bool {
+	switch method {
+	case ProbeHead:
+		return statusCode == http.StatusMethodNotAllowed || statusCode == http.StatusNotImplemented
+	case ProbeRange:
+		return statusCode == http.StatusRequestedRangeNotSatisfiable || statusCode == http.StatusNotImplemented
+	}
+	return false
+}
+
+// Probe returns the status code of the url. 200 and 206 mean that the url exists.
+func (prober *Prober) Probe(ctx context.Context, client *http.Client, urlStr string) (int, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		method := prober.methodFor(u.Host)
+		statusCode, err := probeWith(ctx, client, urlStr, method)
+		if err != nil {
+			return 0, err
+		}
+		if method < ProbeGet && rejectsMethod(method, statusCode) {
+			prober.fallBack(u.Host, method)
+			continue
+		}
+		return statusCode, nil
+	}
+}
+
+// Check returns nil if the url exists, a StatusError if it doesn't, or the error of the request.
+func (prober *Prober) Check(ctx context.Context, client *http.Client, urlStr string) error {
+	statusCode, err := prober.Probe(ctx, client, urlStr)
+	if err != nil {
+		return err
+	}
+	if statusCode != http.StatusOK && statusCode != http.StatusPartialContent {
+		return &StatusError{StatusCode: statusCode}
+	}
+	return nil
+}
+
+func (prober *Prober) Exists(ctx context.Context, client *http.Client, urlStr string) bool {
+	return prober.Check(ctx, client, urlStr) == nil
+}
+
+func probeWith(ctx context.Context, client *http.Client, urlStr string, method ProbeMethod) (int, error) {
+	httpMethod := http.MethodGet
+	if method == ProbeHead {
+		httpMethod = http.MethodHead
+	}
+	req, err := http.NewRequestWithContext(ctx, httpMethod, urlStr, nil)
+	if err != nil {
+		return 0, err
+	}
+	if method == ProbeRange {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := doRequest(client, req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if method == ProbeGet {
+		io.Copy(io.Discard, resp.Body)
+	} else {
+		// a server that ignores the range sends the whole body, so only drain a little for connection reuse
+		io.CopyN(io.Discard, resp.Body, 4096)
+	}
+	return resp.StatusCode, nil
+}
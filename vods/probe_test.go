@@ -0,0 +1,38 @@
+package vods_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func TestProberFallsBackFromHead(t *testing.T) {
+	mu := sync.Mutex{}
+	methods := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method+" "+r.Header.Get("Range"))
+		mu.Unlock()
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Path != "/0.ts" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "0.ts", time.Time{}, strings.NewReader(strings.Repeat("x", 1<<20)))
+	}))
+	defer server.Close()
+	prober := vods.NewProber(vods.ProbeHead)
+	assertEqual(t, prober.Exists(context.Background(), server.Client(), server.URL+"/0.ts"), true)
+	assertEqual(t, prober.Exists(context.Background(), server.Client(), server.URL+"/1.ts"), false)
+	server.Close()
+	assertEqual(t, strings.Join(methods, ","), "HEAD ,GET bytes=0-0,GET bytes=0-0")
+}
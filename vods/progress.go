@@ -0,0 +1,48 @@
+package vods
+
+// An EventKind is what happened in a search or a validation.
+type EventKind string
+
+const (
+	CandidateTried  EventKind = "candidate-tried"  // a request for a candidate playlist was sent
+	CandidateFailed EventKind = "candidate-failed" // a candidate playlist was not valid
+	PlaylistFound   EventKind = "playlist-found"   // a candidate playlist was valid
+	SegmentChecked  EventKind = "segment-checked"  // a segment was checked
+)
+
+// An Event describes progress of the library.
+type Event struct {
+	Kind    EventKind
+	Dwp     *DomainWithPath // the candidate of candidate and playlist events
+	Err     error           // why the candidate failed
+	Segment *SegmentResult  // the result of segment events
+	Done    int             // segments checked so far
+	Total   int             // segments in the playlist
+	Limit   int             // requests currently allowed in flight
+}
+
+// A Reporter receives events. It must be safe to call from multiple goroutines.
+type Reporter interface {
+	Report(event Event)
+}
+
+// ReporterFunc adapts a function to a Reporter.
+type ReporterFunc func(event Event)
+
+func (f ReporterFunc) Report(event Event) {
+	f(event)
+}
+
+type nopReporter struct{}
+
+func (nopReporter) Report(event Event) {}
+
+// NopReporter ignores every event.
+var NopReporter Reporter = nopReporter{}
+
+func reporterOrNop(reporter Reporter) Reporter {
+	if reporter == nil {
+		return NopReporter
+	}
+	return reporter
+}
@@ -0,0 +1,70 @@
+package vods_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+type recordingReporter struct {
+	mu     sync.Mutex
+	events []vods.Event
+}
+
+func (reporter *recordingReporter) Report(event vods.Event) {
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+	reporter.events = append(reporter.events, event)
+}
+
+func (reporter *recordingReporter) count(kind vods.EventKind) int {
+	count := 0
+	for _, event := range reporter.events {
+		if event.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSearchPlannerReportsCandidates(t *testing.T) {
+	videoData := vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038929, 0)}
+	validPath := videoData.WithOffset(40).GetUrlPath(vods.UnixPathScheme)
+	server := newPlaylistServer(validPath, newInFlight())
+	defer server.Close()
+	reporter := &recordingReporter{}
+	planner := vods.SearchPlanner{Concurrency: 1, Reporter: reporter}
+	_, err := planner.GetFirstValidDwp(context.Background(), videoData.GetDomainWithPathsList([]string{server.URL + "/"}, 60, vods.UnixPathScheme), server.Client())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, reporter.count(vods.CandidateTried), 41)
+	assertEqual(t, reporter.count(vods.CandidateFailed), 40)
+	assertEqual(t, reporter.count(vods.PlaylistFound), 1)
+	last := reporter.events[len(reporter.events)-1]
+	assertEqual(t, last.Kind, vods.PlaylistFound)
+	assertEqual(t, last.Dwp.Path.UrlPath, validPath)
+}
+
+func TestValidatorReportsSegments(t *testing.T) {
+	requests := atomic.Int64{}
+	server := newSegmentServer([][2]int{{10, 20}}, &requests)
+	defer server.Close()
+	reporter := &recordingReporter{}
+	validator := &vods.ExhaustiveValidator{Limiter: vods.NewFixedLimiter(4), Reporter: reporter}
+	validatePlaylist(t, newSegmentPlaylist(t, server.URL, 50), validator, server.Client(), vods.NewProber(vods.ProbeHead))
+	assertEqual(t, reporter.count(vods.SegmentChecked), 50)
+	invalid := 0
+	for i, event := range reporter.events {
+		assertEqual(t, event.Done, i+1)
+		assertEqual(t, event.Total, 50)
+		if !event.Segment.Valid {
+			invalid++
+		}
+	}
+	assertEqual(t, invalid, 10)
+}
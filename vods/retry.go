@@ -0,0 +1,200 @@
+package vods
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrForbidden   = errors.New("forbidden")
+	ErrRateLimited = errors.New("rate limited")
+	ErrTransport   = errors.New("transport error")
+)
+
+// A StatusError is an unexpected status code. It unwraps to ErrNotFound, ErrForbidden, ErrRateLimited
+// or, for the other server errors that are retried, ErrTransport, depending on the status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprint("status code is ", err.StatusCode)
+}
+
+func (err *StatusError) Unwrap() error {
+	switch err.StatusCode {
+	case http.StatusNotFound, http.StatusGone:
+		return ErrNotFound
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return ErrForbidden
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return ErrRateLimited
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return ErrTransport
+	}
+	return nil
+}
+
+// IsWrongCandidate reports whether err means that a url does not exist,
+// as opposed to the request not getting a real answer.
+// The CDN answers 403 for paths that don't exist.
+func IsWrongCandidate(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrForbidden)
+}
+
+// IsThrottled reports whether err means that the request should be tried again later.
+func IsThrottled(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTransport)
+}
+
+// RetryPolicy decides when and how long to wait before sending a request again.
+type RetryPolicy struct {
+	MaxAttempts     int           // total attempts, including the first one
+	BaseDelay       time.Duration // delay after the first attempt, doubled after every attempt
+	MaxDelay        time.Duration // upper bound of a single delay, including one from Retry-After
+	Jitter          float64       // fraction of the delay that is randomized, between 0 and 1
+	RetryableStatus map[int]bool
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   250 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+	Jitter:      0.5,
+	RetryableStatus: map[int]bool{
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+		http.StatusBadGateway:          true,
+		http.StatusServiceUnavailable:  true,
+		http.StatusGatewayTimeout:      true,
+	},
+}
+
+// Delay returns how long to wait after the given attempt, starting from 1.
+// A Retry-After header of the response takes precedence over the exponential backoff.
+func (policy RetryPolicy) Delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return policy.capDelay(retryAfter)
+		}
+	}
+	delay := policy.BaseDelay
+	for i := 1; i < attempt && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = policy.capDelay(delay)
+	jitter := time.Duration(policy.Jitter * float64(delay) * rand.Float64())
+	return delay - jitter
+}
+
+func (policy RetryPolicy) capDelay(delay time.Duration) time.Duration {
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		return policy.MaxDelay
+	}
+	return delay
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date), true
+	}
+	return 0, false
+}
+
+// retryTransport retries requests according to a policy. Each attempt has its own timeout.
+type retryTransport struct {
+	base    http.RoundTripper
+	policy  RetryPolicy
+	timeout time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		attemptReq, err := rewindRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.roundTripOnce(attemptReq)
+		if ctx.Err() != nil || attempt >= t.policy.MaxAttempts {
+			return resp, err
+		}
+		if err == nil && !t.policy.RetryableStatus[resp.StatusCode] {
+			return resp, nil
+		}
+		delay := t.policy.Delay(attempt, resp)
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (t *retryTransport) roundTripOnce(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the timeout also covers reading the body
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	return clone, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelOnClose) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
+
+// doRequest sends a request and wraps failures to get a response in ErrTransport.
+func doRequest(client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctxErr := req.Context().Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("%w: %v", ErrTransport, err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,89 @@
+package vods_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func TestRetryPolicyRetriesThrottledRequests(t *testing.T) {
+	requests := atomic.Int64{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("#EXTM3U\n"))
+	}))
+	defer server.Close()
+	client, _ := vods.NewClient(vods.DefaultClientOptions)
+	dwp, err := vods.UrlToDomainWithPath(server.URL + "/c5992ececce7bd7d350d_gmhikaru_47198535725_1664038929")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	body, err := dwp.GetM3U8Body(context.Background(), client)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, string(body), "#EXTM3U\n")
+	assertEqual(t, requests.Load(), int64(3))
+}
+
+func TestStatusErrorClassification(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	options := vods.DefaultClientOptions
+	options.Retry.MaxAttempts = 1
+	client, _ := vods.NewClient(options)
+	dwp, err := vods.UrlToDomainWithPath(server.URL + "/c5992ececce7bd7d350d_gmhikaru_47198535725_1664038929")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	_, err = dwp.GetM3U8Body(context.Background(), client)
+	assertEqual(t, errors.Is(err, vods.ErrForbidden), true)
+	assertEqual(t, vods.IsWrongCandidate(err), true)
+	assertEqual(t, vods.IsThrottled(err), false)
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := vods.RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	assertEqual(t, policy.Delay(1, nil), time.Second)
+	assertEqual(t, policy.Delay(3, nil), 4*time.Second)
+	assertEqual(t, policy.Delay(10, nil), 5*time.Second)
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"2"}}}
+	assertEqual(t, policy.Delay(1, resp), 2*time.Second)
+}
+
+func TestStatusErrorUnwrap(t *testing.T) {
+	for _, test := range []struct {
+		statusCode int
+		want       error
+	}{
+		{http.StatusNotFound, vods.ErrNotFound},
+		{http.StatusGone, vods.ErrNotFound},
+		{http.StatusForbidden, vods.ErrForbidden},
+		{http.StatusTooManyRequests, vods.ErrRateLimited},
+		{http.StatusServiceUnavailable, vods.ErrRateLimited},
+		{http.StatusInternalServerError, vods.ErrTransport},
+		{http.StatusBadGateway, vods.ErrTransport},
+		{http.StatusGatewayTimeout, vods.ErrTransport},
+	} {
+		err := &vods.StatusError{StatusCode: test.statusCode}
+		if !errors.Is(err, test.want) {
+			t.Fatalf("status %v is not %v", test.statusCode, test.want)
+		}
+	}
+	for statusCode := range vods.DefaultRetryPolicy.RetryableStatus {
+		assertEqual(t, vods.IsThrottled(&vods.StatusError{StatusCode: statusCode}), true)
+	}
+	assertEqual(t, vods.IsThrottled(&vods.StatusError{StatusCode: http.StatusBadRequest}), false)
+}
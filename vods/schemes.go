@@ -0,0 +1,154 @@
+package vods
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A PathScheme is a hypothesis for how the url path of a video is named.
+type PathScheme interface {
+	Name() string
+	UrlPath(videoData *VideoData) string
+}
+
+// A HashedPathScheme names paths {hash}{sep}{streamername}{sep}{videoid}{sep}{time},
+// where the hash is the first HashLength hex characters of the sha1 of everything after the first separator.
+type HashedPathScheme struct {
+	SchemeName string
+	HashLength int
+	Separator  string
+	FormatTime func(time.Time) string
+}
+
+var (
+	// e.g. c5992ececce7bd7d350d_gmhikaru_47198535725_1664038929
+	UnixPathScheme PathScheme = &HashedPathScheme{SchemeName: "unix", HashLength: 20, Separator: "_", FormatTime: timeToUnixString}
+	// very rarely, a stream will use the seconds of the time rather than the unix time
+	SecondsPathScheme PathScheme = &HashedPathScheme{SchemeName: "seconds", HashLength: 20, Separator: "_", FormatTime: timeToSecond}
+)
+
+func (scheme *HashedPathScheme) Name() string {
+	return scheme.SchemeName
+}
+
+func (scheme *HashedPathScheme) UrlPath(videoData *VideoData) string {
+	baseUrl := strings.Join([]string{videoData.StreamerName, videoData.VideoId, scheme.FormatTime(videoData.Time)}, scheme.Separator)
+	hasher := sha1.New()
+	io.WriteString(hasher, baseUrl)
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	hashLength := scheme.HashLength
+	if hashLength > len(hash) {
+		hashLength = len(hash)
+	}
+	return hash[:hashLength] + scheme.Separator + baseUrl
+}
+
+func timeToUnixString(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+func timeToSecond(t time.Time) string {
+	return strconv.Itoa(t.Second())
+}
+
+// A RegisteredScheme is a PathScheme with the weight of its paths in the search.
+// See InterleavePaths for how weights are used.
+type RegisteredScheme struct {
+	Scheme PathScheme
+	Weight float64
+}
+
+var (
+	schemesMu         sync.RWMutex
+	registeredSchemes = []RegisteredScheme{
+		{Scheme: UnixPathScheme, Weight: 1},
+		{Scheme: SecondsPathScheme, Weight: 0.25},
+	}
+)
+
+// RegisterPathScheme adds a scheme to the search. Scheme names must be unique.
+func RegisterPathScheme(scheme PathScheme, weight float64) error {
+	if weight <= 0 {
+		return errors.New("weight must be positive")
+	}
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	for _, registered := range registeredSchemes {
+		if registered.Scheme.Name() == scheme.Name() {
+			return errors.New(fmt.Sprint("path scheme ", scheme.Name(), " is already registered"))
+		}
+	}
+	registeredSchemes = append(registeredSchemes, RegisteredScheme{Scheme: scheme, Weight: weight})
+	return nil
+}
+
+// UnregisterPathScheme removes a scheme from the search. It returns false if there was no such scheme.
+func UnregisterPathScheme(name string) bool {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	for i, registered := range registeredSchemes {
+		if registered.Scheme.Name() == name {
+			registeredSchemes = append(registeredSchemes[:i:i], registeredSchemes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func RegisteredPathSchemes() []RegisteredScheme {
+	schemesMu.RLock()
+	defer schemesMu.RUnlock()
+	return append([]RegisteredScheme{}, registeredSchemes...)
+}
+
+// A PathParser is a PathScheme whose url paths can be parsed back into video data,
+// for schemes that UrlPathToVideoData can't parse.
+type PathParser interface {
+	ParseUrlPath(urlPath string) (*VideoData, error)
+}
+
+// minUnixPathTime is before every stream. The time of a path is only a unix time if it is after it,
+// since the seconds of SecondsPathScheme name the same paths as the unix times 0 to 59.
+var minUnixPathTime = time.Date(2011, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// DetectPathScheme returns the first registered scheme that names urlPath, and the video data of urlPath.
+// Schemes that aren't PathParsers are checked with the video data of UrlPathToVideoData.
+// If no registered scheme names urlPath, it is assumed to be of UnixPathScheme.
+func DetectPathScheme(urlPath string) (PathScheme, *VideoData, error) {
+	parsed, parseErr := UrlPathToVideoData(urlPath)
+	for _, registered := range RegisteredPathSchemes() {
+		videoData := parsed
+		if parser, ok := registered.Scheme.(PathParser); ok {
+			var err error
+			if videoData, err = parser.ParseUrlPath(urlPath); err != nil {
+				continue
+			}
+		}
+		if registered.Scheme == UnixPathScheme && videoData != nil && videoData.Time.Before(minUnixPathTime) {
+			continue
+		}
+		if videoData != nil && registered.Scheme.UrlPath(videoData) == urlPath {
+			return registered.Scheme, videoData, nil
+		}
+	}
+	if parseErr != nil {
+		return nil, nil, parseErr
+	}
+	return UnixPathScheme, parsed, nil
+}
+
+func LookupPathScheme(name string) (PathScheme, bool) {
+	for _, registered := range RegisteredPathSchemes() {
+		if registered.Scheme.Name() == name {
+			return registered.Scheme, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,149 @@
+package vods_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+// dashedPathScheme names paths {streamername}-{videoid}-{unixtime}, which UrlPathToVideoData can't parse.
+type dashedPathScheme struct{}
+
+func (dashedPathScheme) Name() string {
+	return "dashed"
+}
+
+func (dashedPathScheme) UrlPath(videoData *vods.VideoData) string {
+	return strings.Join([]string{videoData.StreamerName, videoData.VideoId, strconv.FormatInt(videoData.Time.Unix(), 10)}, "-")
+}
+
+func (dashedPathScheme) ParseUrlPath(urlPath string) (*vods.VideoData, error) {
+	parts := strings.Split(urlPath, "-")
+	if len(parts) != 3 {
+		return nil, errors.New("url path is not dashed")
+	}
+	unixtime, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &vods.VideoData{StreamerName: parts[0], VideoId: parts[1], Time: time.Unix(unixtime, 0)}, nil
+}
+
+var shortPathScheme = &vods.HashedPathScheme{
+	SchemeName: "short",
+	HashLength: 8,
+	Separator:  "_",
+	FormatTime: func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) },
+}
+
+func registerPathScheme(t *testing.T, scheme vods.PathScheme, weight float64) {
+	t.Helper()
+	if err := vods.RegisterPathScheme(scheme, weight); err != nil {
+		t.Fatalf(err.Error())
+	}
+	t.Cleanup(func() { vods.UnregisterPathScheme(scheme.Name()) })
+}
+
+func TestRegisterPathScheme(t *testing.T) {
+	if err := vods.RegisterPathScheme(shortPathScheme, 0); err == nil {
+		t.Fatalf("registered a scheme with weight 0")
+	}
+	registerPathScheme(t, shortPathScheme, 0.5)
+	if err := vods.RegisterPathScheme(shortPathScheme, 1); err == nil {
+		t.Fatalf("registered scheme %v twice", shortPathScheme.Name())
+	}
+	scheme, ok := vods.LookupPathScheme("short")
+	assertEqual(t, ok, true)
+	assertEqual(t, scheme.Name(), "short")
+	registered := vods.RegisteredPathSchemes()
+	assertEqual(t, registered[len(registered)-1].Weight, 0.5)
+
+	videoData := vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038929, 0)}
+	found := false
+	for _, path := range videoData.GetRegisteredVideoPaths(vods.OffsetRange(0, 1)) {
+		found = found || path.UrlPath == videoData.GetUrlPath(shortPathScheme)
+	}
+	assertEqual(t, found, true)
+
+	assertEqual(t, vods.UnregisterPathScheme("short"), true)
+	assertEqual(t, vods.UnregisterPathScheme("short"), false)
+	_, ok = vods.LookupPathScheme("short")
+	assertEqual(t, ok, false)
+	_, ok = vods.LookupPathScheme(vods.UnixPathScheme.Name())
+	assertEqual(t, ok, true)
+}
+
+func TestUrlToDomainWithPathDetectsScheme(t *testing.T) {
+	videoData := vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038929, 0)}
+	domain := "https://d1m7jfoe9zdc1j.cloudfront.net/"
+	shortUrl := domain + videoData.GetUrlPath(shortPathScheme) + "/chunked/index-dvr.m3u8"
+	dashedUrl := domain + videoData.GetUrlPath(dashedPathScheme{}) + "/chunked/index-dvr.m3u8"
+
+	dwp, err := vods.UrlToDomainWithPath(shortUrl)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, dwp.Path.Scheme.Name(), vods.UnixPathScheme.Name())
+	if _, err := vods.UrlToDomainWithPath(dashedUrl); err == nil {
+		t.Fatalf("parsed %v without its scheme", dashedUrl)
+	}
+
+	registerPathScheme(t, shortPathScheme, 1)
+	registerPathScheme(t, dashedPathScheme{}, 1)
+	for _, test := range []struct {
+		url    string
+		scheme string
+	}{
+		{domain + videoData.GetUrlPath(vods.UnixPathScheme) + "/chunked/index-dvr.m3u8", vods.UnixPathScheme.Name()},
+		{shortUrl, shortPathScheme.Name()},
+		{dashedUrl, dashedPathScheme{}.Name()},
+	} {
+		dwp, err := vods.UrlToDomainWithPath(test.url)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		assertEqual(t, dwp.Path.Scheme.Name(), test.scheme)
+		assertEqual(t, dwp.Domain, domain)
+		assertEqual(t, dwp.GetIndexDvrUrl(), test.url)
+		assertEqual(t, dwp.GetVideoData().StreamerName, videoData.StreamerName)
+		assertEqual(t, dwp.GetVideoData().VideoId, videoData.VideoId)
+		assertEqual(t, dwp.GetVideoData().Time.Unix(), videoData.Time.Unix())
+	}
+
+	// the seconds of a seconds path are not a unix time
+	secondsUrl := domain + videoData.GetUrlPath(vods.SecondsPathScheme) + "/chunked/index-dvr.m3u8"
+	dwp, err = vods.UrlToDomainWithPath(secondsUrl)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, dwp.Path.Scheme.Name(), vods.SecondsPathScheme.Name())
+	assertEqual(t, dwp.GetIndexDvrUrl(), secondsUrl)
+	assertEqual(t, dwp.GetVideoData().Time.Second(), videoData.Time.Second())
+}
+
+func TestWindowSearchRegisteredScheme(t *testing.T) {
+	registerPathScheme(t, shortPathScheme, 1)
+	videoData := vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038929, 0)}
+	validPath := videoData.WithOffset(2).GetUrlPath(shortPathScheme)
+	server := newPlaylistServer(validPath, newInFlight())
+	defer server.Close()
+	search := &vods.WindowSearch{
+		StreamerName: videoData.StreamerName,
+		VideoId:      videoData.VideoId,
+		Window:       &vods.TimeWindow{Start: videoData.Time.Add(-10 * time.Second), Guess: videoData.Time, End: videoData.Time.Add(10 * time.Second)},
+		Domains:      []string{server.URL + "/"},
+		ChunkSeconds: 5,
+		Planner:      &vods.SearchPlanner{Concurrency: 4},
+	}
+	result, err := search.Run(context.Background(), server.Client())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, result.Dwp.Path.UrlPath, validPath)
+	assertEqual(t, result.Dwp.Path.Scheme.Name(), shortPathScheme.Name())
+}
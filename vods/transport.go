@@ -0,0 +1,208 @@
+package vods
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClientOptions configures the client made by NewClient.
+type ClientOptions struct {
+	Timeout             time.Duration // timeout of a single request and of dialing
+	MaxIdleConnsPerHost int           // warm connections kept per domain
+	IdleConnTimeout     time.Duration
+	DisableHTTP2        bool
+	TLSClientConfig     *tls.Config
+	Retry               RetryPolicy
+	ProxyURL            *url.URL          // http, https or socks5 proxy, nil to use the environment
+	UserAgent           string            // empty to use the Go default
+	Headers             http.Header       // sent with every request
+	Resolve             map[string]string // host -> ip address to dial instead of resolving the host
+	Network             string            // "tcp4" or "tcp6" to only dial one IP version, empty for both
+}
+
+var DefaultClientOptions = ClientOptions{
+	Timeout:             10 * time.Second,
+	MaxIdleConnsPerHost: 64,
+	IdleConnTimeout:     90 * time.Second,
+	Retry:               DefaultRetryPolicy,
+}
+
+// ConnStats counts how connections of a client are used.
+// With HTTP/2, many requests to a domain are multiplexed over a single connection.
+type ConnStats struct {
+	dials    atomic.Int64
+	requests atomic.Int64
+	reused   atomic.Int64
+	http2    atomic.Int64
+}
+
+// A ConnStatsSnapshot is the value of ConnStats at a point in time.
+type ConnStatsSnapshot struct {
+	Dials    int64 // new TCP connections
+	Requests int64 // requests that got a connection
+	Reused   int64 // requests that reused an existing connection
+	HTTP2    int64 // responses over HTTP/2
+}
+
+func (stats *ConnStats) Snapshot() ConnStatsSnapshot {
+	return ConnStatsSnapshot{
+		Dials:    stats.dials.Load(),
+		Requests: stats.requests.Load(),
+		Reused:   stats.reused.Load(),
+		HTTP2:    stats.http2.Load(),
+	}
+}
+
+func (snapshot ConnStatsSnapshot) String() string {
+	return fmt.Sprint(snapshot.Dials, " connections dialed, ", snapshot.Requests, " requests, ", snapshot.Reused, " reused connections, ", snapshot.HTTP2, " over HTTP/2")
+}
+
+type statsTransport struct {
+	base  http.RoundTripper
+	stats *ConnStats
+}
+
+func (t *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.stats.requests.Add(1)
+			if info.Reused {
+				t.stats.reused.Add(1)
+			}
+		},
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err == nil && resp.ProtoMajor == 2 {
+		t.stats.http2.Add(1)
+	}
+	return resp, err
+}
+
+// NewClient makes a client that attempts HTTP/2 so that requests to a domain share a connection.
+// Domains that don't support HTTP/2 fall back to a pool of warm HTTP/1.1 connections.
+// Requests are retried according to the retry policy, and the timeout applies to each attempt.
+func NewClient(options ClientOptions) (*http.Client, *ConnStats) {
+	stats := &ConnStats{}
+	dialer := &net.Dialer{
+		Timeout:   options.Timeout,
+		KeepAlive: 30 * time.Second,
+	}
+	proxy := http.ProxyFromEnvironment
+	if options.ProxyURL != nil {
+		proxy = http.ProxyURL(options.ProxyURL)
+	}
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			stats.dials.Add(1)
+			if options.Network != "" {
+				network = options.Network
+			}
+			return dialer.DialContext(ctx, network, resolveAddr(addr, options.Resolve))
+		},
+		ForceAttemptHTTP2:   !options.DisableHTTP2,
+		MaxIdleConnsPerHost: options.MaxIdleConnsPerHost,
+		IdleConnTimeout:     options.IdleConnTimeout,
+		TLSHandshakeTimeout: options.Timeout,
+		TLSClientConfig:     options.TLSClientConfig,
+	}
+	if options.DisableHTTP2 {
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	client := &http.Client{
+		Transport: &retryTransport{
+			base: &headerTransport{
+				base:      &statsTransport{base: transport, stats: stats},
+				userAgent: options.UserAgent,
+				headers:   options.Headers,
+			},
+			policy:  options.Retry,
+			timeout: options.Timeout,
+		},
+	}
+	return client, stats
+}
+
+// resolveAddr replaces the host of addr if it is pinned to an ip address.
+func resolveAddr(addr string, resolve map[string]string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip, ok := resolve[host]; ok {
+		return net.JoinHostPort(ip, port)
+	}
+	return addr
+}
+
+type headerTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	headers   http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.userAgent == "" && len(t.headers) == 0 {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	for key, values := range t.headers {
+		if _, ok := req.Header[key]; !ok {
+			req.Header[key] = values
+		}
+	}
+	if t.userAgent != "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// LoadCertPool returns the system certificates together with the PEM certificates in each file.
+func LoadCertPool(paths []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New(fmt.Sprint("no certificates found in ", path))
+		}
+	}
+	return pool, nil
+}
+
+// WarmConnections opens a connection to each domain so that the requests of a search don't all dial at once.
+func WarmConnections(ctx context.Context, client *http.Client, domains []string) {
+	wg := sync.WaitGroup{}
+	for _, domain := range domains {
+		wg.Add(1)
+		go func(domain string) {
+			defer wg.Done()
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, domain, nil)
+			if err != nil {
+				return
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+		}(domain)
+	}
+	wg.Wait()
+}
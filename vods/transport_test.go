@@ -0,0 +1,221 @@
+package vods_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func newTLSServer(enableHTTP2 bool) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	server.EnableHTTP2 = enableHTTP2
+	server.StartTLS()
+	return server
+}
+
+func newTestClient(server *httptest.Server, disableHTTP2 bool) (*http.Client, *vods.ConnStats) {
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	options := vods.DefaultClientOptions
+	options.DisableHTTP2 = disableHTTP2
+	options.TLSClientConfig = &tls.Config{RootCAs: roots}
+	return vods.NewClient(options)
+}
+
+// probeConcurrently issues numRequests requests at once, like a search over many paths of a domain.
+func probeConcurrently(client *http.Client, url string, numRequests int) {
+	wg := sync.WaitGroup{}
+	for i := 0; i < numRequests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(url)
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestHTTP2ReusesConnection(t *testing.T) {
+	server := newTLSServer(true)
+	defer server.Close()
+	client, stats := newTestClient(server, false)
+	vods.WarmConnections(context.Background(), client, []string{server.URL + "/"})
+	probeConcurrently(client, server.URL+"/index-dvr.m3u8", 60)
+	snapshot := stats.Snapshot()
+	assertEqual(t, snapshot.Dials, int64(1))
+	assertEqual(t, snapshot.HTTP2, int64(61))
+}
+
+func BenchmarkProbeTransport(b *testing.B) {
+	for _, bench := range []struct {
+		name         string
+		disableHTTP2 bool
+	}{{name: "HTTP1", disableHTTP2: true}, {name: "HTTP2", disableHTTP2: false}} {
+		b.Run(bench.name, func(b *testing.B) {
+			server := newTLSServer(!bench.disableHTTP2)
+			defer server.Close()
+			client, stats := newTestClient(server, bench.disableHTTP2)
+			vods.WarmConnections(context.Background(), client, []string{server.URL + "/"})
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				probeConcurrently(client, server.URL+"/index-dvr.m3u8", 60)
+			}
+			b.ReportMetric(float64(stats.Snapshot().Dials)/float64(b.N), "dials/op")
+		})
+	}
+}
+
+func TestResolveAddr(t *testing.T) {
+	resolve := map[string]string{"vod-secure.twitch.tv": "151.101.2.167", "d1m7jfoe9zdc1j.cloudfront.net": "2600:9000::1"}
+	for _, test := range []struct {
+		addr string
+		want string
+	}{
+		{"vod-secure.twitch.tv:443", "151.101.2.167:443"},
+		{"vod-secure.twitch.tv:80", "151.101.2.167:80"},
+		{"d1m7jfoe9zdc1j.cloudfront.net:443", "[2600:9000::1]:443"},
+		{"vod-metro.twitch.tv:443", "vod-metro.twitch.tv:443"},
+		{"vod-secure.twitch.tv", "vod-secure.twitch.tv"},
+		{"[::1]:443", "[::1]:443"},
+	} {
+		assertEqual(t, vods.ResolveAddr(test.addr, resolve), test.want)
+	}
+	assertEqual(t, vods.ResolveAddr("vod-secure.twitch.tv:443", nil), "vod-secure.twitch.tv:443")
+}
+
+func TestClientResolve(t *testing.T) {
+	hosts := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts <- r.Host
+	}))
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	options := vods.DefaultClientOptions
+	options.Resolve = map[string]string{"vods.invalid": "127.0.0.1"}
+	options.Network = "tcp4"
+	client, _ := vods.NewClient(options)
+	resp, err := client.Get("http://vods.invalid:" + port + "/")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	resp.Body.Close()
+	assertEqual(t, <-hosts, "vods.invalid:"+port)
+}
+
+func TestClientHeaders(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+	}))
+	defer server.Close()
+	options := vods.DefaultClientOptions
+	options.UserAgent = "govods-test"
+	options.Headers = http.Header{}
+	options.Headers.Set("Accept-Language", "en")
+	options.Headers.Set("X-Govods", "1")
+	client, _ := vods.NewClient(options)
+	for _, test := range []struct {
+		acceptLanguage string
+		want           string
+	}{{"", "en"}, {"de", "de"}} {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		if test.acceptLanguage != "" {
+			// headers of the request are kept
+			req.Header.Set("Accept-Language", test.acceptLanguage)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		resp.Body.Close()
+		header := <-headers
+		assertEqual(t, header.Get("User-Agent"), "govods-test")
+		assertEqual(t, header.Get("X-Govods"), "1")
+		assertEqual(t, header.Get("Accept-Language"), test.want)
+		assertEqual(t, req.Header.Get("X-Govods"), "")
+	}
+}
+
+func TestClientProxy(t *testing.T) {
+	urls := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		urls <- r.URL.String()
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	options := vods.DefaultClientOptions
+	options.ProxyURL = proxyURL
+	client, _ := vods.NewClient(options)
+	resp, err := client.Get("http://vods.invalid/index-dvr.m3u8")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	resp.Body.Close()
+	assertEqual(t, <-urls, "http://vods.invalid/index-dvr.m3u8")
+}
+
+func TestLoadCertPool(t *testing.T) {
+	server := newTLSServer(false)
+	defer server.Close()
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "ca.pem")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, certPem, 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+	empty := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(empty, []byte("not a certificate"), 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+	if _, err := vods.LoadCertPool([]string{empty}); err == nil {
+		t.Fatalf("loaded a bundle without certificates")
+	}
+	if _, err := vods.LoadCertPool([]string{filepath.Join(dir, "missing.pem")}); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf(`got %v want %v`, err, os.ErrNotExist)
+	}
+
+	options := vods.DefaultClientOptions
+	options.Retry.MaxAttempts = 1
+	client, _ := vods.NewClient(options)
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatalf("trusted the certificate of the test server without the bundle")
+	}
+	pool, err := vods.LoadCertPool([]string{bundle})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	options.TLSClientConfig = &tls.Config{RootCAs: pool}
+	client, _ = vods.NewClient(options)
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	resp.Body.Close()
+	assertEqual(t, resp.StatusCode, http.StatusForbidden)
+}
@@ -0,0 +1,214 @@
+package vods
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafov/m3u8"
+)
+
+// Tags that Twitch adds to the header of index-dvr playlists.
+const (
+	TagTwitchElapsedSecs = "#EXT-X-TWITCH-ELAPSED-SECS:" // seconds of the stream before the first segment
+	TagTwitchTotalSecs   = "#EXT-X-TWITCH-TOTAL-SECS:"   // seconds of the whole stream
+	TagID3EquivTDTG      = "#ID3-EQUIV-TDTG:"            // when the playlist was generated
+)
+
+const twitchTimeLayout = "2006-01-02T15:04:05"
+
+// A TwitchSecondsTag is a Twitch tag whose value is a number of seconds.
+type TwitchSecondsTag struct {
+	Name    string
+	Seconds float64
+}
+
+func (tag *TwitchSecondsTag) TagName() string {
+	return tag.Name
+}
+
+func (tag *TwitchSecondsTag) Encode() *bytes.Buffer {
+	return bytes.NewBufferString(tag.String())
+}
+
+func (tag *TwitchSecondsTag) String() string {
+	return tag.Name + strconv.FormatFloat(tag.Seconds, 'f', 3, 64)
+}
+
+// A TwitchTimeTag is the #ID3-EQUIV-TDTG tag. The time has no zone in the playlist and is UTC.
+type TwitchTimeTag struct {
+	Time time.Time
+}
+
+func (tag *TwitchTimeTag) TagName() string {
+	return TagID3EquivTDTG
+}
+
+func (tag *TwitchTimeTag) Encode() *bytes.Buffer {
+	return bytes.NewBufferString(tag.String())
+}
+
+func (tag *TwitchTimeTag) String() string {
+	return TagID3EquivTDTG + tag.Time.UTC().Format(twitchTimeLayout)
+}
+
+// A rawTag re-emits a line that could not be parsed, so lenient decoding keeps it.
+type rawTag struct {
+	name string
+	line string
+}
+
+func (tag *rawTag) TagName() string {
+	return tag.name
+}
+
+func (tag *rawTag) Encode() *bytes.Buffer {
+	return bytes.NewBufferString(tag.line)
+}
+
+func (tag *rawTag) String() string {
+	return tag.line
+}
+
+type twitchTagDecoder struct {
+	name   string
+	decode func(value string) (m3u8.CustomTag, error)
+}
+
+func (decoder *twitchTagDecoder) TagName() string {
+	return decoder.name
+}
+
+// Decode never returns a nil tag, because the m3u8 package keeps the tag even if there's an error when it isn't strict.
+func (decoder *twitchTagDecoder) Decode(line string) (m3u8.CustomTag, error) {
+	tag, err := decoder.decode(strings.TrimPrefix(line, decoder.name))
+	if err != nil {
+		return &rawTag{name: decoder.name, line: line}, errors.New(fmt.Sprint("invalid tag ", line, ": ", err))
+	}
+	return tag, nil
+}
+
+func (decoder *twitchTagDecoder) SegmentTag() bool {
+	return false
+}
+
+func decodeSecondsTag(name string) func(value string) (m3u8.CustomTag, error) {
+	return func(value string) (m3u8.CustomTag, error) {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &TwitchSecondsTag{Name: name, Seconds: seconds}, nil
+	}
+}
+
+func decodeTimeTag(value string) (m3u8.CustomTag, error) {
+	t, err := time.Parse(twitchTimeLayout, value)
+	if err != nil {
+		return nil, err
+	}
+	return &TwitchTimeTag{Time: t}, nil
+}
+
+// TwitchTagDecoders parse the Twitch tags of a playlist into its Custom tags, so they are encoded again.
+func TwitchTagDecoders() []m3u8.CustomDecoder {
+	return []m3u8.CustomDecoder{
+		&twitchTagDecoder{name: TagTwitchElapsedSecs, decode: decodeSecondsTag(TagTwitchElapsedSecs)},
+		&twitchTagDecoder{name: TagTwitchTotalSecs, decode: decodeSecondsTag(TagTwitchTotalSecs)},
+		&twitchTagDecoder{name: TagID3EquivTDTG, decode: decodeTimeTag},
+	}
+}
+
+// GetTwitchSeconds returns the seconds of a TwitchSecondsTag of the playlist, such as TagTwitchTotalSecs.
+func GetTwitchSeconds(mediapl *m3u8.MediaPlaylist, name string) (float64, bool) {
+	tag, ok := mediapl.Custom[name].(*TwitchSecondsTag)
+	if !ok {
+		return 0, false
+	}
+	return tag.Seconds, true
+}
+
+// twitchTagOrder is the order of the Twitch tags in the playlists of Twitch.
+var twitchTagOrder = []string{TagID3EquivTDTG, TagTwitchElapsedSecs, TagTwitchTotalSecs}
+
+// orderedTags encodes custom tags in a fixed order: the Twitch tags in the order of twitchTagOrder, then the others by name.
+type orderedTags []m3u8.CustomTag
+
+func (tags orderedTags) TagName() string {
+	return ""
+}
+
+func (tags orderedTags) Encode() *bytes.Buffer {
+	lines := []string{}
+	for _, tag := range tags {
+		if buf := tag.Encode(); buf != nil {
+			lines = append(lines, buf.String())
+		}
+	}
+	return bytes.NewBufferString(strings.Join(lines, "\n"))
+}
+
+func (tags orderedTags) String() string {
+	return tags.Encode().String()
+}
+
+func tagRank(name string) int {
+	for i, twitchTag := range twitchTagOrder {
+		if name == twitchTag {
+			return i
+		}
+	}
+	return len(twitchTagOrder)
+}
+
+// EncodeMediaPlaylist encodes mediapl like its Encode method, except the custom tags are always in the same order.
+// The m3u8 package encodes them in the order of a map, so encoding the same playlist twice could differ.
+func EncodeMediaPlaylist(mediapl *m3u8.MediaPlaylist) *bytes.Buffer {
+	if len(mediapl.Custom) < 2 {
+		return mediapl.Encode()
+	}
+	tags := orderedTags{}
+	for _, tag := range mediapl.Custom {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		ri, rj := tagRank(tags[i].TagName()), tagRank(tags[j].TagName())
+		if ri != rj {
+			return ri < rj
+		}
+		return tags[i].TagName() < tags[j].TagName()
+	})
+	custom := mediapl.Custom
+	mediapl.Custom = map[string]m3u8.CustomTag{"": tags}
+	defer func() { mediapl.Custom = custom }()
+	return mediapl.Encode()
+}
+
+// withTotalSecs returns a copy of the custom tags with TagTwitchTotalSecs set to the seconds of the segments,
+// after the TagTwitchElapsedSecs seconds before them. A total that could not be parsed is dropped.
+func withTotalSecs(custom map[string]m3u8.CustomTag, segments []*m3u8.MediaSegment) map[string]m3u8.CustomTag {
+	if _, ok := custom[TagTwitchTotalSecs]; !ok {
+		return custom
+	}
+	tags := map[string]m3u8.CustomTag{}
+	for name, tag := range custom {
+		tags[name] = tag
+	}
+	delete(tags, TagTwitchTotalSecs)
+	if _, ok := custom[TagTwitchTotalSecs].(*TwitchSecondsTag); !ok {
+		return tags
+	}
+	seconds := 0.0
+	if elapsed, ok := custom[TagTwitchElapsedSecs].(*TwitchSecondsTag); ok {
+		seconds = elapsed.Seconds
+	}
+	for _, segment := range segments {
+		seconds += segment.Duration
+	}
+	tags[TagTwitchTotalSecs] = &TwitchSecondsTag{Name: TagTwitchTotalSecs, Seconds: seconds}
+	return tags
+}
@@ -0,0 +1,76 @@
+package vods_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+const twitchPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#ID3-EQUIV-TDTG:2022-09-24T20:30:12
+#EXT-X-PLAYLIST-TYPE:EVENT
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TWITCH-ELAPSED-SECS:0.000
+#EXT-X-TWITCH-TOTAL-SECS:20.500
+#EXTINF:10.000,
+0.ts
+#EXTINF:10.500,
+1-unmuted.ts
+#EXT-X-ENDLIST
+`
+
+func TestTwitchTags(t *testing.T) {
+	mediapl, err := vods.DecodeMediaPlaylistFilterNilSegments([]byte(twitchPlaylist), true)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	totalSecs, ok := vods.GetTwitchSeconds(mediapl, vods.TagTwitchTotalSecs)
+	assertEqual(t, ok, true)
+	assertEqual(t, totalSecs, 20.5)
+	tdtg := mediapl.Custom[vods.TagID3EquivTDTG].(*vods.TwitchTimeTag)
+	assertEqual(t, tdtg.Time.Equal(time.Date(2022, 9, 24, 20, 30, 12, 0, time.UTC)), true)
+	results := []vods.SegmentResult{{Index: 0, Valid: true}, {Index: 1, Valid: false}}
+	filtered, err := vods.GetMediaPlaylistWithValidSegments(mediapl, results)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	// the total of a filtered playlist is the seconds of its segments
+	totalSecs, ok = vods.GetTwitchSeconds(filtered, vods.TagTwitchTotalSecs)
+	assertEqual(t, ok, true)
+	assertEqual(t, totalSecs, 10.0)
+	totalSecs, _ = vods.GetTwitchSeconds(mediapl, vods.TagTwitchTotalSecs)
+	assertEqual(t, totalSecs, 20.5)
+	tags := "#ID3-EQUIV-TDTG:2022-09-24T20:30:12\n#EXT-X-TWITCH-ELAPSED-SECS:0.000\n#EXT-X-TWITCH-TOTAL-SECS:10.000\n"
+	encoded := vods.EncodeMediaPlaylist(filtered).String()
+	if !strings.Contains(encoded, tags) {
+		t.Fatalf("the tags are not in order in the encoded playlist:\n%v", encoded)
+	}
+
+	// a playlist with every segment keeps its total
+	results[1].Valid = true
+	unfiltered, err := vods.GetMediaPlaylistWithValidSegments(mediapl, results)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if !strings.Contains(vods.EncodeMediaPlaylist(unfiltered).String(), "#EXT-X-TWITCH-TOTAL-SECS:20.500\n") {
+		t.Fatalf("the total of an unfiltered playlist changed")
+	}
+}
+
+func TestInvalidTwitchTag(t *testing.T) {
+	invalid := strings.Replace(twitchPlaylist, "TOTAL-SECS:20.500", "TOTAL-SECS:abc", 1)
+	if _, err := vods.DecodeMediaPlaylistFilterNilSegments([]byte(invalid), true); err == nil {
+		t.Fatalf("expected an error decoding strictly")
+	}
+	mediapl, err := vods.DecodeMediaPlaylistFilterNilSegments([]byte(invalid), false)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if !strings.Contains(vods.EncodeMediaPlaylist(mediapl).String(), "#EXT-X-TWITCH-TOTAL-SECS:abc\n") {
+		t.Fatalf("the invalid tag was not kept")
+	}
+}
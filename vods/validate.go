@@ -0,0 +1,216 @@
+package vods
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// A SegmentResult is the outcome of checking a single segment url.
+type SegmentResult struct {
+	Index      int
+	Url        string
+	Valid      bool
+	StatusCode int   // 0 if no response was received or the segment was not checked
+	Err        error // why the segment is invalid, nil if it is valid
+	Checked    bool  // false if the validity was assumed from the neighbouring segments
+}
+
+// A SegmentValidator decides which segment urls are valid.
+type SegmentValidator interface {
+	// Validate returns the results sorted by index. If ctx is done before every url is decided,
+	// it returns the results decided so far together with the error of ctx.
+	// No goroutines are left running once it returns.
+	Validate(ctx context.Context, urls []string, client *http.Client, prober *Prober) ([]SegmentResult, error)
+}
+
+// An ExhaustiveValidator checks every segment.
+type ExhaustiveValidator struct {
+	Limiter  Limiter
+	Reporter Reporter // receives a SegmentChecked event for each segment, may be nil
+}
+
+// A SamplingValidator checks every Stride-th segment, then bisects between samples that disagree
+// to find where each missing range starts and ends. The segments between two samples that agree are
+// assumed to be the same as the samples, so a missing range shorter than Stride can be missed.
+// With Verify, the assumed segments are checked afterwards.
+type SamplingValidator struct {
+	Limiter  Limiter
+	Stride   int
+	Verify   bool
+	Reporter Reporter // receives a SegmentChecked event for each segment that is checked, may be nil
+}
+
+type progress struct {
+	reporter Reporter
+	limiter  Limiter
+	done     int
+	total    int
+}
+
+func (progress *progress) checked(result SegmentResult) {
+	progress.done++
+	progress.reporter.Report(Event{
+		Kind:    SegmentChecked,
+		Segment: &result,
+		Done:    progress.done,
+		Total:   progress.total,
+		Limit:   progress.limiter.Limit(),
+	})
+}
+
+func (validator *ExhaustiveValidator) Validate(ctx context.Context, urls []string, client *http.Client, prober *Prober) ([]SegmentResult, error) {
+	indices := []int{}
+	for i := range urls {
+		indices = append(indices, i)
+	}
+	progress := &progress{reporter: reporterOrNop(validator.Reporter), limiter: validator.Limiter, total: len(urls)}
+	results := map[int]SegmentResult{}
+	err := checkUrls(ctx, urls, indices, validator.Limiter, client, prober, progress, results)
+	return sortedResults(results), err
+}
+
+func (validator *SamplingValidator) Validate(ctx context.Context, urls []string, client *http.Client, prober *Prober) ([]SegmentResult, error) {
+	numUrls := len(urls)
+	results := map[int]SegmentResult{}
+	if numUrls == 0 {
+		return []SegmentResult{}, nil
+	}
+	stride := validator.Stride
+	if stride < 1 {
+		stride = 1
+	}
+	progress := &progress{reporter: reporterOrNop(validator.Reporter), limiter: validator.Limiter, total: numUrls}
+	samples := []int{}
+	for i := 0; i < numUrls; i += stride {
+		samples = append(samples, i)
+	}
+	if samples[len(samples)-1] != numUrls-1 {
+		samples = append(samples, numUrls-1)
+	}
+	if err := checkUrls(ctx, urls, samples, validator.Limiter, client, prober, progress, results); err != nil {
+		return sortedResults(results), err
+	}
+	type boundary struct {
+		lo int
+		hi int
+	}
+	boundaries := []boundary{}
+	for i := 1; i < len(samples); i++ {
+		lo, hi := samples[i-1], samples[i]
+		if results[lo].Valid != results[hi].Valid && hi-lo > 1 {
+			boundaries = append(boundaries, boundary{lo: lo, hi: hi})
+		}
+	}
+	// bisect every boundary at the same time, one round of requests per halving
+	for len(boundaries) > 0 {
+		mids := []int{}
+		for _, b := range boundaries {
+			mids = append(mids, (b.lo+b.hi)/2)
+		}
+		if err := checkUrls(ctx, urls, mids, validator.Limiter, client, prober, progress, results); err != nil {
+			return sortedResults(results), err
+		}
+		narrowed := []boundary{}
+		for _, b := range boundaries {
+			mid := (b.lo + b.hi) / 2
+			if results[mid].Valid == results[b.lo].Valid {
+				b.lo = mid
+			} else {
+				b.hi = mid
+			}
+			if b.hi-b.lo > 1 {
+				narrowed = append(narrowed, b)
+			}
+		}
+		boundaries = narrowed
+	}
+	checked := sortedResults(results)
+	assumed := []int{}
+	for i := 1; i < len(checked); i++ {
+		lo, hi := checked[i-1], checked[i]
+		for index := lo.Index + 1; index < hi.Index; index++ {
+			results[index] = SegmentResult{Index: index, Url: urls[index], Valid: lo.Valid, Err: assumedErr(lo)}
+			assumed = append(assumed, index)
+		}
+	}
+	if validator.Verify {
+		if err := checkUrls(ctx, urls, assumed, validator.Limiter, client, prober, progress, results); err != nil {
+			return sortedResults(results), err
+		}
+	}
+	return sortedResults(results), nil
+}
+
+// assumedErr is the error of a segment assumed to be the same as a neighbour.
+func assumedErr(neighbour SegmentResult) error {
+	if neighbour.Valid {
+		return nil
+	}
+	return fmt.Errorf("assumed from segment %d: %w", neighbour.Index, neighbour.Err)
+}
+
+// checkUrls checks the urls at the indices with as many requests in flight as the limiter allows,
+// adding a result for each one to results. It waits for every request it started before returning.
+func checkUrls(ctx context.Context, urls []string, indices []int, limiter Limiter, client *http.Client, prober *Prober, progress *progress, results map[int]SegmentResult) error {
+	ctx, cancel := context.WithCancel(ctx)
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	defer cancel()
+	// buffered so that no request blocks on sending after the results stop being read
+	resultsCh := make(chan SegmentResult, len(indices))
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, index := range indices {
+			token, err := limiter.Acquire(ctx)
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func(index int) {
+				defer wg.Done()
+				result := checkUrl(ctx, client, prober, index, urls[index])
+				limiter.Release(token, result.Err)
+				resultsCh <- result
+			}(index)
+		}
+	}()
+	for range indices {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case result := <-resultsCh:
+			if ctx.Err() != nil && !result.Valid {
+				// the request was probably cancelled, so the segment is undecided
+				return ctx.Err()
+			}
+			progress.checked(result)
+			results[result.Index] = result
+		}
+	}
+	return nil
+}
+
+func checkUrl(ctx context.Context, client *http.Client, prober *Prober, index int, url string) SegmentResult {
+	result := SegmentResult{Index: index, Url: url, Checked: true}
+	result.StatusCode, result.Err = prober.Probe(ctx, client, url)
+	if result.Err == nil && result.StatusCode != http.StatusOK && result.StatusCode != http.StatusPartialContent {
+		result.Err = &StatusError{StatusCode: result.StatusCode}
+	}
+	result.Valid = result.Err == nil
+	return result
+}
+
+func sortedResults(results map[int]SegmentResult) []SegmentResult {
+	sorted := []SegmentResult{}
+	for _, result := range results {
+		sorted = append(sorted, result)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Index < sorted[j].Index
+	})
+	return sorted
+}
@@ -0,0 +1,111 @@
+package vods_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+	"github.com/grafov/m3u8"
+)
+
+// newSegmentServer serves {i}.ts unless i is in one of the missing ranges [start, end).
+func newSegmentServer(missing [][2]int, requests *atomic.Int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		index, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".ts"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for _, gap := range missing {
+			if index >= gap[0] && index < gap[1] {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func newSegmentPlaylist(t testing.TB, baseUrl string, numSegments int) *m3u8.MediaPlaylist {
+	mediapl, err := m3u8.NewMediaPlaylist(0, uint(numSegments))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	for i := 0; i < numSegments; i++ {
+		mediapl.Append(fmt.Sprint(baseUrl, "/", i, ".ts"), 10, "")
+	}
+	mediapl.Close()
+	return mediapl
+}
+
+func validatePlaylist(t testing.TB, rawPlaylist *m3u8.MediaPlaylist, validator vods.SegmentValidator, client *http.Client, prober *vods.Prober) *m3u8.MediaPlaylist {
+	results, err := vods.ValidateSegments(context.Background(), rawPlaylist, validator, client, prober)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	mediapl, err := vods.GetMediaPlaylistWithValidSegments(rawPlaylist, results)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	return mediapl
+}
+
+func TestSamplingValidatorMatchesExhaustive(t *testing.T) {
+	requests := atomic.Int64{}
+	server := newSegmentServer([][2]int{{0, 7}, {130, 275}, {600, 700}, {990, 1000}}, &requests)
+	defer server.Close()
+	client := server.Client()
+	prober := vods.NewProber(vods.ProbeHead)
+	exhaustive := validatePlaylist(t, newSegmentPlaylist(t, server.URL, 1000), &vods.ExhaustiveValidator{Limiter: vods.NewFixedLimiter(16)}, client, prober)
+	exhaustiveRequests := requests.Swap(0)
+	sampled := validatePlaylist(t, newSegmentPlaylist(t, server.URL, 1000), &vods.SamplingValidator{Limiter: vods.NewFixedLimiter(16), Stride: 50}, client, prober)
+	assertEqual(t, sampled.String(), exhaustive.String())
+	if sampledRequests := requests.Load(); sampledRequests*5 > exhaustiveRequests {
+		t.Fatalf("sampling made %v requests, exhaustive made %v", sampledRequests, exhaustiveRequests)
+	}
+}
+
+func TestSamplingValidatorVerify(t *testing.T) {
+	requests := atomic.Int64{}
+	server := newSegmentServer([][2]int{{10, 12}}, &requests)
+	defer server.Close()
+	validator := &vods.SamplingValidator{Limiter: vods.NewFixedLimiter(16), Stride: 50, Verify: true}
+	mediapl := validatePlaylist(t, newSegmentPlaylist(t, server.URL, 100), validator, server.Client(), vods.NewProber(vods.ProbeHead))
+	assertEqual(t, len(mediapl.Segments), 98)
+}
+
+func TestValidateSegmentsCancel(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/0.ts" {
+			select {
+			case <-block:
+			case <-r.Context().Done():
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	defer close(block)
+	ctx, cancel := context.WithCancel(context.Background())
+	validator := &vods.ExhaustiveValidator{Limiter: vods.NewFixedLimiter(4)}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	results, err := vods.ValidateSegments(ctx, newSegmentPlaylist(t, server.URL, 100), validator, server.Client(), vods.NewProber(vods.ProbeHead))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf(`got %v want %v`, err, context.Canceled)
+	}
+	assertEqual(t, len(results), 1)
+	assertEqual(t, results[0].StatusCode, http.StatusOK)
+}
@@ -0,0 +1,121 @@
+package vods
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	WatchPending  = "pending"  // found in a feed and not resolved yet
+	WatchResolved = "resolved" // the playlist was written
+	WatchFailed   = "failed"   // the last attempt failed
+)
+
+// A WatchedVod is a stream that was found in an input feed of the watch command.
+type WatchedVod struct {
+	Streamer      string     `json:"streamer"`
+	VideoId       string     `json:"videoId"`
+	StartTime     time.Time  `json:"startTime"`
+	Source        string     `json:"source"` // the format of the feed it was found in
+	Status        string     `json:"status"`
+	FoundAt       time.Time  `json:"foundAt"`
+	Attempts      int        `json:"attempts"`
+	LastAttempt   *time.Time `json:"lastAttempt,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	File          string     `json:"file,omitempty"`
+	Downloaded    bool       `json:"downloaded,omitempty"`
+	DownloadError string     `json:"downloadError,omitempty"`
+}
+
+func (vod *WatchedVod) VideoData() *VideoData {
+	return &VideoData{StreamerName: vod.Streamer, VideoId: vod.VideoId, Time: vod.StartTime}
+}
+
+// A WatchState is the streams the watch command has found, by video id, so it continues where it left off after a restart.
+type WatchState struct {
+	Vods map[string]*WatchedVod `json:"vods"`
+}
+
+func LoadWatchState(path string) (*WatchState, error) {
+	state := &WatchState{Vods: map[string]*WatchedVod{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	if state.Vods == nil {
+		state.Vods = map[string]*WatchedVod{}
+	}
+	return state, nil
+}
+
+func (state *WatchState) Save(path string) error {
+	return writeJsonFile(path, state)
+}
+
+// Found adds a stream of a feed as pending, and reports whether it is new.
+func (state *WatchState) Found(videoData *VideoData, source string, now time.Time) bool {
+	if _, ok := state.Vods[videoData.VideoId]; ok {
+		return false
+	}
+	state.Vods[videoData.VideoId] = &WatchedVod{
+		Streamer:  videoData.StreamerName,
+		VideoId:   videoData.VideoId,
+		StartTime: videoData.Time,
+		Source:    source,
+		Status:    WatchPending,
+		FoundAt:   now,
+	}
+	return true
+}
+
+// Attempted records the result of resolving the vod with the video id. file is the written playlist.
+func (state *WatchState) Attempted(videoId string, file string, err error, now time.Time) {
+	vod, ok := state.Vods[videoId]
+	if !ok {
+		return
+	}
+	vod.Attempts++
+	vod.LastAttempt = &now
+	if err != nil {
+		vod.Status = WatchFailed
+		vod.Error = err.Error()
+		return
+	}
+	vod.Status = WatchResolved
+	vod.Error = ""
+	vod.File = file
+}
+
+// Due returns the vods that are pending, and the ones that failed fewer than maxAttempts times
+// and were last attempted at least retryAfter ago, the oldest stream first. maxAttempts 0 means no limit.
+func (state *WatchState) Due(maxAttempts int, retryAfter time.Duration, now time.Time) []*WatchedVod {
+	due := []*WatchedVod{}
+	for _, vod := range state.Vods {
+		switch {
+		case vod.Status == WatchPending:
+		case vod.Status != WatchFailed:
+			continue
+		case maxAttempts > 0 && vod.Attempts >= maxAttempts:
+			continue
+		case vod.LastAttempt != nil && now.Sub(*vod.LastAttempt) < retryAfter:
+			continue
+		}
+		due = append(due, vod)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].StartTime.Equal(due[j].StartTime) {
+			return due[i].VideoId < due[j].VideoId
+		}
+		return due[i].StartTime.Before(due[j].StartTime)
+	})
+	return due
+}
@@ -0,0 +1,46 @@
+package vods_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func TestWatchStateDue(t *testing.T) {
+	now := time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "watch.json")
+	state, err := vods.LoadWatchState(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	for i, id := range []string{"1", "2", "3", "4"} {
+		videoData := &vods.VideoData{StreamerName: "streamer", VideoId: id, Time: now.AddDate(0, 0, i-10)}
+		assertEqual(t, state.Found(videoData, vods.FeedSullyGnome, now), true)
+	}
+	assertEqual(t, state.Found(&vods.VideoData{StreamerName: "streamer", VideoId: "1"}, vods.FeedGovods, now), false)
+	state.Attempted("1", "one.m3u8", nil, now)
+	state.Attempted("2", "", errors.New("not found"), now)
+	state.Attempted("3", "", errors.New("not found"), now.Add(-2*time.Hour))
+	state.Attempted("3", "", errors.New("not found"), now.Add(-2*time.Hour))
+	if err := state.Save(path); err != nil {
+		t.Fatalf(err.Error())
+	}
+	state, err = vods.LoadWatchState(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, state.Vods["1"].Status, vods.WatchResolved)
+	assertEqual(t, state.Vods["1"].File, "one.m3u8")
+	assertEqual(t, state.Vods["2"].Error, "not found")
+	due := state.Due(3, time.Hour, now)
+	assertEqual(t, len(due), 2)
+	assertEqual(t, due[0].VideoId, "3")
+	assertEqual(t, due[1].VideoId, "4")
+	due = state.Due(2, 0, now)
+	assertEqual(t, len(due), 2)
+	assertEqual(t, due[0].VideoId, "2")
+	assertEqual(t, due[1].VideoId, "4")
+}
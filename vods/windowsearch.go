@@ -0,0 +1,169 @@
+package vods
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+)
+
+var (
+	ErrBudgetExhausted = errors.New("request budget exhausted before the window was searched")
+	ErrWindowExhausted = errors.New("no valid url found in the time window")
+)
+
+// A WindowCheckpoint records how far a WindowSearch got so that it can be resumed.
+type WindowCheckpoint struct {
+	StreamerName string    `json:"streamer"`
+	VideoId      string    `json:"videoid"`
+	Start        time.Time `json:"start"`
+	Guess        time.Time `json:"guess"`
+	End          time.Time `json:"end"`
+	ChunkSeconds int       `json:"chunkSeconds"`
+	NextChunk    int       `json:"nextChunk"`
+	Requests     int       `json:"requests"`
+}
+
+// A WindowSearch brute forces every second of a TimeWindow with the registered path schemes, one chunk of seconds at a time.
+// Chunks are searched starting from the guess of the window and moving outwards.
+type WindowSearch struct {
+	StreamerName   string
+	VideoId        string
+	Window         *TimeWindow
+	Domains        []string
+	ChunkSeconds   int
+	MaxRequests    int    // maximum number of requests for a single run, 0 for no limit
+	CheckpointPath string // where progress is saved, empty to disable checkpointing
+	Planner        *SearchPlanner
+}
+
+func (search *WindowSearch) Run(ctx context.Context, client *http.Client) (*ValidDwpResponse, error) {
+	if search.ChunkSeconds <= 0 {
+		return nil, errors.New("chunk seconds must be positive")
+	}
+	checkpoint, err := search.loadCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	chunks := checkpoint.chunkStarts()
+	requests := 0
+	for checkpoint.NextChunk < len(chunks) {
+		chunkStart := chunks[checkpoint.NextChunk]
+		seconds := checkpoint.ChunkSeconds
+		if remaining := int(checkpoint.End.Sub(chunkStart) / time.Second); remaining < seconds {
+			seconds = remaining
+		}
+		videoData := &VideoData{StreamerName: search.StreamerName, VideoId: search.VideoId, Time: chunkStart}
+		videoPaths := videoData.GetRegisteredVideoPaths(OffsetRange(0, seconds-1))
+		cost := len(videoPaths) * len(search.Domains)
+		if search.MaxRequests > 0 && requests+cost > search.MaxRequests {
+			return nil, ErrBudgetExhausted
+		}
+		dwpAndBody, err := search.planner().GetFirstValidDwp(ctx, NewDomainWithPathsList(search.Domains, videoPaths), client)
+		if err == nil {
+			return dwpAndBody, search.removeCheckpoint()
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if errors.Is(err, ErrRequestLimit) || IsThrottled(err) {
+			return nil, err
+		}
+		requests += cost
+		checkpoint.Requests += cost
+		checkpoint.NextChunk++
+		if err := search.saveCheckpoint(checkpoint); err != nil {
+			return nil, err
+		}
+	}
+	if err := search.removeCheckpoint(); err != nil {
+		return nil, err
+	}
+	return nil, ErrWindowExhausted
+}
+
+func (search *WindowSearch) planner() SearchPlanner {
+	if search.Planner == nil {
+		return DefaultSearchPlanner
+	}
+	return *search.Planner
+}
+
+// chunkStarts returns the start of every chunk, ordered by distance from the guess.
+func (checkpoint *WindowCheckpoint) chunkStarts() []time.Time {
+	chunkDuration := time.Duration(checkpoint.ChunkSeconds) * time.Second
+	numChunks := int((checkpoint.End.Sub(checkpoint.Start) + chunkDuration - 1) / chunkDuration)
+	guessChunk := int(checkpoint.Guess.Sub(checkpoint.Start) / chunkDuration)
+	if guessChunk >= numChunks {
+		guessChunk = numChunks - 1
+	}
+	if guessChunk < 0 {
+		guessChunk = 0
+	}
+	if numChunks <= 0 {
+		return []time.Time{}
+	}
+	result := []time.Time{checkpoint.Start.Add(time.Duration(guessChunk) * chunkDuration)}
+	for distance := 1; len(result) < numChunks; distance++ {
+		for _, chunk := range []int{guessChunk + distance, guessChunk - distance} {
+			if chunk >= 0 && chunk < numChunks {
+				result = append(result, checkpoint.Start.Add(time.Duration(chunk)*chunkDuration))
+			}
+		}
+	}
+	return result
+}
+
+func (search *WindowSearch) newCheckpoint() *WindowCheckpoint {
+	return &WindowCheckpoint{
+		StreamerName: search.StreamerName,
+		VideoId:      search.VideoId,
+		Start:        search.Window.Start,
+		Guess:        search.Window.Guess,
+		End:          search.Window.End,
+		ChunkSeconds: search.ChunkSeconds,
+	}
+}
+
+// loadCheckpoint resumes from the saved checkpoint if it belongs to the same search.
+// Otherwise, it starts a new one from the window.
+func (search *WindowSearch) loadCheckpoint() (*WindowCheckpoint, error) {
+	if search.CheckpointPath == "" {
+		return search.newCheckpoint(), nil
+	}
+	data, err := os.ReadFile(search.CheckpointPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return search.newCheckpoint(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &WindowCheckpoint{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, err
+	}
+	if checkpoint.StreamerName != search.StreamerName || checkpoint.VideoId != search.VideoId || checkpoint.ChunkSeconds != search.ChunkSeconds {
+		return search.newCheckpoint(), nil
+	}
+	return checkpoint, nil
+}
+
+func (search *WindowSearch) saveCheckpoint(checkpoint *WindowCheckpoint) error {
+	if search.CheckpointPath == "" {
+		return nil
+	}
+	return writeJsonFile(search.CheckpointPath, checkpoint)
+}
+
+func (search *WindowSearch) removeCheckpoint() error {
+	if search.CheckpointPath == "" {
+		return nil
+	}
+	err := os.Remove(search.CheckpointPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+package vods_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/auoie/goVods/vods"
+)
+
+func newTestWindowSearch(videoData *vods.VideoData, domain string, checkpointPath string) *vods.WindowSearch {
+	return &vods.WindowSearch{
+		StreamerName:   videoData.StreamerName,
+		VideoId:        videoData.VideoId,
+		Window:         &vods.TimeWindow{Start: videoData.Time, Guess: videoData.Time.Add(30 * time.Second), End: videoData.Time.Add(60 * time.Second)},
+		Domains:        []string{domain},
+		ChunkSeconds:   10,
+		CheckpointPath: checkpointPath,
+		Planner:        &vods.SearchPlanner{Concurrency: 1},
+	}
+}
+
+func TestWindowSearch(t *testing.T) {
+	videoData := vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038900, 0)}
+	validPath := videoData.WithOffset(47).GetUrlPath(vods.UnixPathScheme)
+	server := newPlaylistServer(validPath, newInFlight())
+	defer server.Close()
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+	result, err := newTestWindowSearch(&videoData, server.URL+"/", checkpointPath).Run(context.Background(), server.Client())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, result.Dwp.Path.UrlPath, validPath)
+	if _, err := os.Stat(checkpointPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("got %v want the checkpoint to be removed", err)
+	}
+
+	search := newTestWindowSearch(&videoData, server.URL+"/", checkpointPath)
+	search.Window.End = videoData.Time.Add(40 * time.Second)
+	if _, err := search.Run(context.Background(), server.Client()); !errors.Is(err, vods.ErrWindowExhausted) {
+		t.Fatalf(`got %v want %v`, err, vods.ErrWindowExhausted)
+	}
+}
+
+func TestWindowSearchCheckpoint(t *testing.T) {
+	videoData := vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038900, 0)}
+	// chunks are searched in the order 30, 40, 20, 50, 10, 0 seconds after the start
+	validPath := videoData.WithOffset(5).GetUrlPath(vods.UnixPathScheme)
+	requests := newInFlight()
+	server := newPlaylistServer(validPath, requests)
+	defer server.Close()
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+	chunkCost := len(videoData.GetRegisteredVideoPaths(vods.OffsetRange(0, 9)))
+
+	search := newTestWindowSearch(&videoData, server.URL+"/", checkpointPath)
+	search.MaxRequests = 3*chunkCost + 1
+	if _, err := search.Run(context.Background(), server.Client()); !errors.Is(err, vods.ErrBudgetExhausted) {
+		t.Fatalf(`got %v want %v`, err, vods.ErrBudgetExhausted)
+	}
+	assertEqual(t, requests.started, 3*chunkCost)
+	data, err := os.ReadFile(checkpointPath)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	checkpoint := vods.WindowCheckpoint{}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, checkpoint.NextChunk, 3)
+	assertEqual(t, checkpoint.Requests, 3*chunkCost)
+	assertEqual(t, checkpoint.Start.Equal(videoData.Time), true)
+
+	// the second run resumes from the fourth chunk
+	requests.started = 0
+	result, err := newTestWindowSearch(&videoData, server.URL+"/", checkpointPath).Run(context.Background(), server.Client())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, result.Dwp.Path.UrlPath, validPath)
+	if requests.started > 3*chunkCost {
+		t.Fatalf("%v requests after resuming with 3 chunks of %v requests left", requests.started, chunkCost)
+	}
+	if _, err := os.Stat(checkpointPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("got %v want the checkpoint to be removed", err)
+	}
+}
+
+func TestWindowSearchIgnoresOtherCheckpoint(t *testing.T) {
+	videoData := vods.VideoData{StreamerName: "gmhikaru", VideoId: "47198535725", Time: time.Unix(1664038900, 0)}
+	validPath := videoData.WithOffset(35).GetUrlPath(vods.UnixPathScheme)
+	server := newPlaylistServer(validPath, newInFlight())
+	defer server.Close()
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+	// a checkpoint of another chunk size that already searched past the valid path
+	data, err := json.Marshal(vods.WindowCheckpoint{StreamerName: videoData.StreamerName, VideoId: videoData.VideoId, Start: videoData.Time, Guess: videoData.Time.Add(30 * time.Second), End: videoData.Time.Add(60 * time.Second), ChunkSeconds: 5, NextChunk: 5})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if err := os.WriteFile(checkpointPath, data, 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+	result, err := newTestWindowSearch(&videoData, server.URL+"/", checkpointPath).Run(context.Background(), server.Client())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	assertEqual(t, result.Dwp.Path.UrlPath, validPath)
+}